@@ -1,11 +1,26 @@
 package main
 
 import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/Avik-creator/queue"
+	"github.com/Avik-creator/queue/bolt"
+	"github.com/Avik-creator/queue/sqlite"
 	"github.com/Avik-creator/scheduler"
 	"github.com/Avik-creator/utils"
 	"github.com/Avik-creator/worker"
@@ -13,48 +28,554 @@ import (
 	"github.com/urfave/cli/v2"
 )
 
-var q = queue.NewQueue()
-var s = scheduler.NewScheduler(q)
+var q *queue.JobQueue
+var s *scheduler.Scheduler
+
+// defaultAdminMaxWorkers is the most workers the admin API resizes the pool to
+// when the start command does not autoscale.
+const defaultAdminMaxWorkers = 256
 
 func main() {
+	worker.Register("email", worker.HandlerFunc(sendEmail))
 	StartCLI()
 }
 
+// sendEmail stands in for a real email sender: it takes half a second and
+// fails for error@error.com.
+func sendEmail(ctx context.Context, j utils.Job) ([]byte, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	if j.Payload["to"] == "error@error.com" {
+		return nil, fmt.Errorf("simulated error")
+	}
+
+	fmt.Printf("Handled job : %s for %s\n", j.ID, j.Payload["to"])
+	return json.Marshal(map[string]string{"sent_to": j.Payload["to"]})
+}
+
+// openStorage opens the queue and scheduler the commands work on.
+func openStorage(c *cli.Context) error {
+	var err error
+	q, err = openQueue(c)
+	if err != nil {
+		return fmt.Errorf("failed to open queue: %v", err)
+	}
+	s = scheduler.NewScheduler(q)
+	return nil
+}
+
+// openLocalStorage opens the storage for the pause and resume commands unless
+// they go through the admin API.
+func openLocalStorage(c *cli.Context) error {
+	if c.IsSet("addr") {
+		return nil
+	}
+	return openStorage(c)
+}
+
+func openQueue(c *cli.Context) (*queue.JobQueue, error) {
+	strategy, err := parseStrategy(c.String("strategy"), c.String("weights"))
+	if err != nil {
+		return nil, err
+	}
+	ceiling, err := parsePriority(c.String("aging-ceiling"))
+	if err != nil {
+		return nil, err
+	}
+	opts := []queue.Option{
+		queue.WithStrategy(strategy),
+		queue.WithAging(c.Duration("aging-interval"), ceiling),
+		queue.WithResultRetention(c.Duration("result-retention")),
+	}
+	limits, err := parseCapacity(c.StringSlice("capacity"), c.String("overflow"))
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, limits...)
+	duplicates, err := parseDuplicates(c.String("duplicates"))
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, queue.WithUniqueness(c.Duration("unique-window"), duplicates))
+	switch c.String("expired") {
+	case "discard":
+		opts = append(opts, queue.WithExpiry(queue.DiscardExpired))
+	case "dead-letter":
+		opts = append(opts, queue.WithExpiry(queue.DeadLetterExpired))
+	default:
+		return nil, fmt.Errorf("unknown expiry policy %q", c.String("expired"))
+	}
+
+	dataDir := c.String("data-dir")
+	if dataDir == "" {
+		return queue.NewQueue(opts...), nil
+	}
+
+	switch c.String("storage") {
+	case "log":
+		l, err := queue.OpenLog(dataDir)
+		if errors.Is(err, queue.ErrLogLocked) {
+			// The queue lives in the memory of the process holding the log.
+			return nil, fmt.Errorf("%w; log storage serves one process at a time, use --storage sqlite to run other commands alongside start, or --addr to pause and resume it", err)
+		}
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, queue.WithLog(l), queue.WithSnapshotInterval(c.Duration("snapshot-interval")))
+		return queue.NewQueue(opts...), nil
+	case "sqlite":
+		if err := os.MkdirAll(dataDir, 0o755); err != nil {
+			return nil, err
+		}
+		st, err := sqlite.Open(filepath.Join(dataDir, "jobqueue.db"))
+		if err != nil {
+			return nil, err
+		}
+		// Other processes can enqueue into the same database, so waiting
+		// workers cannot rely on AddJob alone to wake them.
+		opts = append(opts, queue.WithStorage(st), queue.WithPollInterval(time.Second))
+		return queue.NewQueue(opts...), nil
+	case "bolt":
+		if err := os.MkdirAll(dataDir, 0o755); err != nil {
+			return nil, err
+		}
+		st, err := bolt.Open(filepath.Join(dataDir, "jobqueue.bolt"))
+		if errors.Is(err, bolt.ErrLocked) {
+			return nil, fmt.Errorf("%w; bolt storage serves one process at a time, use --storage sqlite to run other commands alongside start, or --addr to pause and resume it", err)
+		}
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, queue.WithStorage(st))
+		return queue.NewQueue(opts...), nil
+	}
+	return nil, fmt.Errorf("unknown storage %q", c.String("storage"))
+}
+
+// parseCapacity turns limits such as "emails=1000" or "emails:low=100" into
+// capacity options that all use the named overflow policy.
+func parseCapacity(limits []string, overflow string) ([]queue.Option, error) {
+	var policy queue.Overflow
+	switch overflow {
+	case "reject":
+		policy = queue.Reject
+	case "block":
+		policy = queue.Block
+	case "drop-oldest":
+		policy = queue.DropOldest
+	case "drop-lowest-priority":
+		policy = queue.DropLowestPriority
+	default:
+		return nil, fmt.Errorf("unknown overflow policy %q", overflow)
+	}
+
+	opts := make([]queue.Option, 0, len(limits))
+	for _, l := range limits {
+		scope, n, ok := strings.Cut(l, "=")
+		max, err := strconv.Atoi(n)
+		if !ok || err != nil || max < 1 {
+			return nil, fmt.Errorf("invalid capacity %q: want queue=max or queue:priority=max", l)
+		}
+		name, p, ok := strings.Cut(scope, ":")
+		if !ok {
+			opts = append(opts, queue.WithCapacity(name, max, policy))
+			continue
+		}
+		priority, err := parsePriority(p)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, queue.WithPriorityCapacity(name, priority, max, policy))
+	}
+	return opts, nil
+}
+
+// serveAdmin serves the admin API of the start command, which the workers
+// command uses: GET /workers returns the pool's stats and PUT
+// /workers?size=n resizes it to at most maxWorkers. The pause and resume
+// commands use GET, POST and DELETE /pauses, which list, add and remove the
+// pauses of q. When token is set every request must carry it as a bearer
+// token.
+func serveAdmin(addr, token string, q *queue.JobQueue, pool *worker.Pool, maxWorkers int) (*http.Server, error) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /workers", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(pool.Stats())
+	})
+	mux.HandleFunc("PUT /workers", func(w http.ResponseWriter, r *http.Request) {
+		n, err := strconv.Atoi(r.FormValue("size"))
+		if err != nil {
+			http.Error(w, "size must be a number", http.StatusBadRequest)
+			return
+		}
+		if n < 0 || n > maxWorkers {
+			http.Error(w, fmt.Sprintf("size must be between 0 and %d", maxWorkers), http.StatusBadRequest)
+			return
+		}
+		if err := pool.Resize(n); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		fmt.Printf("Resized to %d worker(s)\n", n)
+		json.NewEncoder(w).Encode(pool.Stats())
+	})
+	pauses := func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			p := queue.Pause{Queue: r.FormValue("queue"), Type: r.FormValue("type")}
+			if v := r.FormValue("priority"); v != "" {
+				priority, err := parsePriority(v)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				p.Priority = priority
+			}
+			change, verb := q.Pause, "Paused"
+			if r.Method == http.MethodDelete {
+				change, verb = q.Resume, "Resumed"
+			}
+			if err := change(p); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			fmt.Println(verb, p)
+		}
+		paused, err := q.Paused()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(paused)
+	}
+	mux.HandleFunc("GET /pauses", pauses)
+	mux.HandleFunc("POST /pauses", pauses)
+	mux.HandleFunc("DELETE /pauses", pauses)
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	var handler http.Handler = mux
+	if token != "" {
+		handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+				http.Error(w, "missing or wrong admin token", http.StatusUnauthorized)
+				return
+			}
+			mux.ServeHTTP(w, r)
+		})
+	}
+	srv := &http.Server{Handler: handler}
+	go srv.Serve(ln)
+	return srv, nil
+}
+
+// adminRequest calls the admin API of a start command and decodes its
+// answer into out.
+func adminRequest(method, u, token string, out any) error {
+	req, err := http.NewRequest(method, u, nil)
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%s", strings.TrimSpace(string(msg)))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// pauseRequest sends a pause or resume to the admin API named by the --addr
+// flag, and returns the pauses in effect afterwards.
+func pauseRequest(c *cli.Context, method string, p queue.Pause) ([]queue.Pause, error) {
+	u := url.URL{Scheme: "http", Host: c.String("addr"), Path: "/pauses"}
+	if method != http.MethodGet {
+		v := url.Values{}
+		if p.Queue != "" {
+			v.Set("queue", p.Queue)
+		}
+		if p.Priority != 0 {
+			v.Set("priority", strconv.Itoa(int(p.Priority)))
+		}
+		if p.Type != "" {
+			v.Set("type", p.Type)
+		}
+		u.RawQuery = v.Encode()
+	}
+	var pauses []queue.Pause
+	err := adminRequest(method, u.String(), c.String("token"), &pauses)
+	return pauses, err
+}
+
+// pauseFlags select what the pause and resume commands apply to.
+var pauseFlags = []cli.Flag{
+	&cli.StringFlag{Name: "queue", Usage: "Named queue to pause (default any)"},
+	&cli.StringFlag{Name: "priority", Usage: "Priority bucket to pause: high, medium, low or a number (default any)"},
+	&cli.StringFlag{Name: "type", Usage: "Job type to pause (default any)"},
+	&cli.StringFlag{Name: "addr", Usage: "Send the pause to the --admin-addr of a running start command instead of the storage"},
+	&cli.StringFlag{Name: "token", Usage: "The --admin-token of the start command", EnvVars: []string{"JOBQUEUE_ADMIN_TOKEN"}},
+}
+
+func parsePause(c *cli.Context) (queue.Pause, error) {
+	p := queue.Pause{Queue: c.String("queue"), Type: c.String("type")}
+	if c.IsSet("priority") {
+		priority, err := parsePriority(c.String("priority"))
+		if err != nil {
+			return queue.Pause{}, err
+		}
+		p.Priority = priority
+	}
+	if p == (queue.Pause{}) {
+		return queue.Pause{}, fmt.Errorf("want at least one of --queue, --priority and --type")
+	}
+	return p, nil
+}
+
+func parseDuplicates(s string) (queue.DuplicatePolicy, error) {
+	switch s {
+	case "reject":
+		return queue.RejectDuplicates, nil
+	case "merge":
+		return queue.MergeDuplicates, nil
+	}
+	return 0, fmt.Errorf("unknown duplicate policy %q", s)
+}
+
+// parseStrategy builds the dequeue strategy named by name, with weights given
+// as comma-separated priority=weight pairs such as "high=6,medium=3,low=1".
+func parseStrategy(name, weights string) (queue.Strategy, error) {
+	if name == "strict" {
+		return queue.Strict(), nil
+	}
+
+	w := make(map[utils.Priority]int)
+	for _, pair := range strings.Split(weights, ",") {
+		p, n, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid weight %q: want priority=weight", pair)
+		}
+		priority, err := parsePriority(p)
+		if err != nil {
+			return nil, err
+		}
+		weight, err := strconv.Atoi(n)
+		if err != nil || weight < 1 {
+			return nil, fmt.Errorf("invalid weight %q: want a positive number", n)
+		}
+		w[priority] = weight
+	}
+
+	switch name {
+	case "wrr":
+		return queue.WeightedRoundRobin(w), nil
+	case "drr":
+		return queue.DeficitRoundRobin(w), nil
+	}
+	return nil, fmt.Errorf("unknown strategy %q", name)
+}
+
+// parseMaxWait parses per-priority waits such as "high=5s,low=1m".
+func parseMaxWait(s string) (map[utils.Priority]time.Duration, error) {
+	waits := make(map[utils.Priority]time.Duration)
+	if s == "" {
+		return waits, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		p, d, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid max wait %q: want priority=duration", pair)
+		}
+		priority, err := parsePriority(p)
+		if err != nil {
+			return nil, err
+		}
+		wait, err := time.ParseDuration(d)
+		if err != nil || wait <= 0 {
+			return nil, fmt.Errorf("invalid max wait %q: want a positive duration", d)
+		}
+		waits[priority] = wait
+	}
+	return waits, nil
+}
+
+// parseTypeTimeouts parses per-type timeouts such as "email=10s,report=5m".
+func parseTypeTimeouts(s string) (map[string]time.Duration, error) {
+	timeouts := make(map[string]time.Duration)
+	if s == "" {
+		return timeouts, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		jobType, d, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || jobType == "" {
+			return nil, fmt.Errorf("invalid timeout %q: want type=duration", pair)
+		}
+		timeout, err := time.ParseDuration(d)
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("invalid timeout %q: want a positive duration", d)
+		}
+		timeouts[jobType] = timeout
+	}
+	return timeouts, nil
+}
+
+func parsePriority(s string) (utils.Priority, error) {
+	switch s {
+	case "high":
+		return utils.High, nil
+	case "medium":
+		return utils.Medium, nil
+	case "low":
+		return utils.Low, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || !utils.Priority(n).Valid() {
+		return 0, fmt.Errorf("invalid priority %q: want high, medium, low or a number from %d to %d", s, utils.MinPriority, utils.MaxPriority)
+	}
+	return utils.Priority(n), nil
+}
+
 func StartCLI() {
 	app := &cli.App{
 		Name:  "Job Queue CLI",
 		Usage: "Manage jobs, workers, and queues",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "data-dir",
+				Value:   ".jobqueue",
+				Usage:   "Directory for the queue's data files (empty keeps the queue in memory only)",
+				EnvVars: []string{"JOBQUEUE_DATA_DIR"},
+			},
+			&cli.StringFlag{
+				Name:    "storage",
+				Value:   "sqlite",
+				Usage:   "Storage backend: sqlite, which every command can share, bolt (a single file, one process at a time) or log (in memory with a write-ahead log, one process at a time)",
+				EnvVars: []string{"JOBQUEUE_STORAGE"},
+			},
+			&cli.DurationFlag{
+				Name:  "snapshot-interval",
+				Value: time.Minute,
+				Usage: "How often to snapshot the queue and compact its log",
+			},
+			&cli.StringFlag{
+				Name:    "strategy",
+				Value:   "strict",
+				Usage:   "How workers choose between priorities: strict, wrr (weighted round-robin) or drr (deficit round-robin)",
+				EnvVars: []string{"JOBQUEUE_STRATEGY"},
+			},
+			&cli.StringFlag{
+				Name:  "weights",
+				Value: "high=6,medium=3,low=1",
+				Usage: "Per-priority weights for wrr, or quanta for drr",
+			},
+			&cli.DurationFlag{
+				Name:  "aging-interval",
+				Usage: "Raise a waiting job's priority by one level each interval (0 disables aging)",
+			},
+			&cli.StringFlag{
+				Name:  "aging-ceiling",
+				Value: "high",
+				Usage: "Highest priority a job can reach by aging",
+			},
+			&cli.StringSliceFlag{
+				Name:  "capacity",
+				Usage: "Limit a queue, or one of its priorities, to a number of queued jobs: queue=max or queue:priority=max (repeatable)",
+			},
+			&cli.StringFlag{
+				Name:  "overflow",
+				Value: "reject",
+				Usage: "What enqueue does when a queue is full: reject, block, drop-oldest or drop-lowest-priority",
+			},
+			&cli.DurationFlag{
+				Name:  "unique-window",
+				Usage: "Keep a unique key taken for at least this long after its job was added, even once the job is done",
+			},
+			&cli.StringFlag{
+				Name:  "duplicates",
+				Value: "reject",
+				Usage: "What enqueue does with a job whose unique key is taken: reject or merge",
+			},
+			&cli.StringFlag{
+				Name:  "expired",
+				Value: "discard",
+				Usage: "What happens to jobs still waiting after their TTL: discard or dead-letter",
+			},
+			&cli.DurationFlag{
+				Name:  "result-retention",
+				Value: queue.DefaultResultRetention,
+				Usage: "How long the results of succeeded jobs are kept",
+			},
+		},
+		Before: func(c *cli.Context) error {
+			// The workers command talks to a running start command instead,
+			// and so do pause and resume when given --addr.
+			switch c.Args().First() {
+			case "workers", "pause", "resume":
+				return nil
+			}
+			return openStorage(c)
+		},
+		After: func(c *cli.Context) error {
+			if q == nil {
+				return nil
+			}
+			return q.Close()
+		},
 		Commands: []*cli.Command{
 			{
 				Name:  "enqueue",
 				Usage: "Enqueue a job",
 				Flags: []cli.Flag{
 					&cli.StringFlag{Name: "to", Required: true},
-					&cli.StringFlag{Name: "priority", Value: "low"},
+					&cli.StringFlag{Name: "queue", Value: utils.DefaultQueue, Usage: "Named queue to add the job to"},
+					&cli.StringFlag{Name: "priority", Value: "low", Usage: "high, medium, low or a number from 1 (most urgent) to 1000"},
 					&cli.IntFlag{Name: "retries", Value: 3},
 					&cli.IntFlag{Name: "delay", Value: 0, Usage: "Delay in seconds"},
+					&cli.DurationFlag{Name: "ttl", Usage: "Give up on the job if no worker has started it within this long (0 never expires)"},
+					&cli.StringFlag{Name: "unique-key", Usage: "Refuse the job while another job with this key is pending or running"},
+					&cli.DurationFlag{Name: "timeout", Usage: "Fail an attempt at the job that runs longer than this (default the type's timeout)"},
 				},
 				Action: func(c *cli.Context) error {
-					priority := utils.Low
-					if c.String("priority") == "high" {
-						priority = utils.High
+					priority, err := parsePriority(c.String("priority"))
+					if err != nil {
+						return err
 					}
 
 					j := utils.Job{
 						ID:         uuid.New().String(),
 						Type:       "email",
+						Queue:      c.String("queue"),
 						Payload:    map[string]string{"to": c.String("to")},
 						Priority:   priority,
 						MaxRetries: c.Int("retries"),
 						CreatedAt:  time.Now(),
+						UniqueKey:  c.String("unique-key"),
+						Timeout:    c.Duration("timeout"),
+					}
+					if ttl := c.Duration("ttl"); ttl > 0 {
+						j.ExpiresAt = j.CreatedAt.Add(ttl)
 					}
 
 					delay := c.Int("delay")
 					if delay > 0 {
-						s.Scheduler(j, time.Duration(delay)*time.Second)
+						if err := s.Scheduler(j, time.Duration(delay)*time.Second); err != nil {
+							return err
+						}
 						fmt.Println("Scheduled job:", j.ID)
 					} else {
-						q.AddJob(j)
+						if err := q.AddJob(j); err != nil {
+							return err
+						}
 						fmt.Println("Enqueued job:", j.ID)
 					}
 					return nil
@@ -65,22 +586,143 @@ func StartCLI() {
 				Usage: "Start worker(s)",
 				Flags: []cli.Flag{
 					&cli.IntFlag{Name: "count", Value: 1},
+					&cli.StringSliceFlag{Name: "queue", Value: cli.NewStringSlice(utils.DefaultQueue), Usage: "Named queues to take jobs from (repeatable)"},
+					&cli.IntFlag{Name: "batch-size", Value: 1, Usage: "Jobs each worker fetches at once"},
+					&cli.DurationFlag{Name: "drain-timeout", Value: worker.DefaultDrainTimeout, Usage: "How long to wait for running jobs on SIGINT or SIGTERM"},
+					&cli.StringFlag{Name: "admin-addr", Usage: "Address to serve the admin API on, for the workers command (default off)"},
+					&cli.StringFlag{Name: "admin-token", Usage: "Token the admin API requires of every request (default none)", EnvVars: []string{"JOBQUEUE_ADMIN_TOKEN"}},
+					&cli.IntFlag{Name: "admin-max-count", Value: defaultAdminMaxWorkers, Usage: "Most workers the admin API may resize the pool to when --max-count is not set"},
+					&cli.StringFlag{Name: "type-timeout", Usage: "Per-type timeouts for jobs without their own, e.g. email=10s"},
+					&cli.IntFlag{Name: "max-count", Usage: "Autoscale between --count and this many workers (default off)"},
+					&cli.IntFlag{Name: "jobs-per-worker", Value: worker.DefaultJobsPerWorker, Usage: "Waiting jobs per worker above which the autoscaler adds workers"},
+					&cli.StringFlag{Name: "max-wait", Usage: "Per-priority waits above which the autoscaler adds workers, e.g. high=5s,low=1m"},
+					&cli.DurationFlag{Name: "scale-interval", Value: worker.DefaultScaleInterval, Usage: "How often the autoscaler checks the queues"},
+					&cli.DurationFlag{Name: "scale-up-cooldown", Value: worker.DefaultScaleUpCooldown, Usage: "How long the autoscaler waits after a resize before adding workers"},
+					&cli.DurationFlag{Name: "scale-down-cooldown", Value: worker.DefaultScaleDownCooldown, Usage: "How long the autoscaler waits after a resize before removing workers"},
 				},
 				Action: func(c *cli.Context) error {
-					count := c.Int("count")
-					for i := 1; i <= count; i++ {
-						w := &worker.Worker{ID: i, Queue: q}
-						w.Start()
+					ctx, stop := signal.NotifyContext(c.Context, os.Interrupt, syscall.SIGTERM)
+					defer stop()
+
+					timeouts, err := parseTypeTimeouts(c.String("type-timeout"))
+					if err != nil {
+						return err
+					}
+					for jobType, d := range timeouts {
+						worker.SetTimeout(jobType, d)
 					}
-					fmt.Printf("Started %d worker(s)\n", count)
-					select {} // block forever
+					maxCount := c.Int("max-count")
+					if maxCount > 0 && maxCount < c.Int("count") {
+						return fmt.Errorf("--max-count %d is below --count %d", maxCount, c.Int("count"))
+					}
+					maxWait, err := parseMaxWait(c.String("max-wait"))
+					if err != nil {
+						return err
+					}
+
+					pool := worker.NewPool(ctx, worker.Worker{
+						Queue:        q,
+						Queues:       c.StringSlice("queue"),
+						BatchSize:    c.Int("batch-size"),
+						DrainTimeout: c.Duration("drain-timeout"),
+					}, c.Int("count"))
+					fmt.Printf("Started %d worker(s)\n", pool.Size())
+
+					if addr := c.String("admin-addr"); addr != "" {
+						maxWorkers := c.Int("admin-max-count")
+						if maxCount > 0 {
+							maxWorkers = maxCount
+						}
+						srv, err := serveAdmin(addr, c.String("admin-token"), q, pool, maxWorkers)
+						if err != nil {
+							pool.Shutdown(context.Background())
+							return fmt.Errorf("failed to serve admin API: %v", err)
+						}
+						defer srv.Close()
+						fmt.Println("Admin API listening on", addr)
+					}
+
+					if maxCount > 0 {
+						a := &worker.Autoscaler{
+							Pool:              pool,
+							Min:               c.Int("count"),
+							Max:               maxCount,
+							Interval:          c.Duration("scale-interval"),
+							JobsPerWorker:     c.Int("jobs-per-worker"),
+							MaxWait:           maxWait,
+							ScaleUpCooldown:   c.Duration("scale-up-cooldown"),
+							ScaleDownCooldown: c.Duration("scale-down-cooldown"),
+						}
+						go func() {
+							if err := a.Run(ctx); err != nil {
+								fmt.Println("Autoscaler stopped:", err)
+							}
+						}()
+						fmt.Printf("Autoscaling between %d and %d worker(s)\n", a.Min, a.Max)
+					}
+
+					<-ctx.Done()
+					// A second signal kills the process without waiting.
+					stop()
+					fmt.Println("Shutting down, waiting for running jobs...")
+
+					drain, cancel := context.WithTimeout(context.Background(), c.Duration("drain-timeout"))
+					defer cancel()
+					return pool.Shutdown(drain)
+				},
+			},
+			{
+				Name:      "workers",
+				Usage:     "Show or change the number of workers of a running start command",
+				ArgsUsage: "[size]",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "addr", Required: true, Usage: "The --admin-addr of the start command"},
+					&cli.StringFlag{Name: "token", Usage: "The --admin-token of the start command", EnvVars: []string{"JOBQUEUE_ADMIN_TOKEN"}},
+				},
+				Action: func(c *cli.Context) error {
+					method := http.MethodGet
+					u := url.URL{Scheme: "http", Host: c.String("addr"), Path: "/workers"}
+					switch c.NArg() {
+					case 0:
+					case 1:
+						if _, err := strconv.Atoi(c.Args().First()); err != nil {
+							return fmt.Errorf("invalid size %q", c.Args().First())
+						}
+						method = http.MethodPut
+						u.RawQuery = url.Values{"size": {c.Args().First()}}.Encode()
+					default:
+						return fmt.Errorf("want at most one size")
+					}
+
+					var stats worker.PoolStats
+					if err := adminRequest(method, u.String(), c.String("token"), &stats); err != nil {
+						return err
+					}
+					fmt.Printf("Workers: %d (%d busy, %d draining)\n", stats.Workers, stats.Busy, stats.Draining)
+					fmt.Printf("Jobs: %d succeeded, %d failed\n", stats.Succeeded, stats.Failed)
+					return nil
 				},
 			},
 			{
 				Name:  "dlq",
 				Usage: "Show dead-letter queue",
+				Flags: []cli.Flag{
+					&cli.StringSliceFlag{Name: "queue", Usage: "Named queues to show (default all)"},
+				},
 				Action: func(c *cli.Context) error {
-					highJobs, mediumJobs, lowJobs, err := q.GetAllDeadLetterJobs()
+					queues := c.StringSlice("queue")
+					if len(queues) == 0 {
+						var err error
+						if queues, err = q.Queues(); err != nil {
+							return fmt.Errorf("failed to list queues: %v", err)
+						}
+					}
+					if len(queues) == 0 {
+						fmt.Println("No failed jobs")
+						return nil
+					}
+
+					highJobs, mediumJobs, lowJobs, err := q.GetAllDeadLetterJobs(queues...)
 					if err != nil {
 						return fmt.Errorf("failed to get dead letter jobs: %v", err)
 					}
@@ -93,8 +735,93 @@ func StartCLI() {
 					}
 					fmt.Println("Dead-letter jobs:")
 					for _, j := range allJobs {
-						fmt.Printf("- %s (%s, priority: %v, retries: %d/%d)\n", j.ID, j.Payload["to"], j.Priority, j.RetryCount, j.MaxRetries)
+						fmt.Printf("- %s (%s, queue: %s, priority: %v, retries: %d/%d", j.ID, j.Payload["to"], j.Queue, j.Priority, j.RetryCount, j.MaxRetries)
+						if j.DeadReason != "" {
+							fmt.Printf(", reason: %s", j.DeadReason)
+						}
+						fmt.Println(")")
+					}
+					return nil
+				},
+			},
+			{
+				Name:  "pause",
+				Usage: "Stop workers taking jobs from a queue, priority or job type; enqueues are still accepted",
+				Flags: append([]cli.Flag{
+					&cli.BoolFlag{Name: "list", Usage: "Show what is paused instead"},
+				}, pauseFlags...),
+				Before: openLocalStorage,
+				Action: func(c *cli.Context) error {
+					if c.Bool("list") {
+						var pauses []queue.Pause
+						var err error
+						if c.IsSet("addr") {
+							pauses, err = pauseRequest(c, http.MethodGet, queue.Pause{})
+						} else {
+							pauses, err = q.Paused()
+						}
+						if err != nil {
+							return err
+						}
+						if len(pauses) == 0 {
+							fmt.Println("Nothing is paused")
+						}
+						for _, p := range pauses {
+							fmt.Println("-", p)
+						}
+						return nil
+					}
+					p, err := parsePause(c)
+					if err != nil {
+						return err
+					}
+					if c.IsSet("addr") {
+						_, err = pauseRequest(c, http.MethodPost, p)
+					} else {
+						err = q.Pause(p)
+					}
+					if err != nil {
+						return err
+					}
+					fmt.Println("Paused", p)
+					return nil
+				},
+			},
+			{
+				Name:   "resume",
+				Usage:  "Resume what an earlier pause with the same flags stopped",
+				Flags:  pauseFlags,
+				Before: openLocalStorage,
+				Action: func(c *cli.Context) error {
+					p, err := parsePause(c)
+					if err != nil {
+						return err
+					}
+					if c.IsSet("addr") {
+						_, err = pauseRequest(c, http.MethodDelete, p)
+					} else {
+						err = q.Resume(p)
+					}
+					if err != nil {
+						return err
+					}
+					fmt.Println("Resumed", p)
+					return nil
+				},
+			},
+			{
+				Name:      "result",
+				Usage:     "Show the result of a succeeded job",
+				ArgsUsage: "<id>",
+				Action: func(c *cli.Context) error {
+					if c.NArg() != 1 {
+						return fmt.Errorf("want exactly one job ID")
+					}
+					r, err := q.GetResult(c.Args().First())
+					if err != nil {
+						return err
 					}
+					fmt.Println(string(r.Data))
 					return nil
 				},
 			},
@@ -102,6 +829,7 @@ func StartCLI() {
 	}
 
 	if err := app.Run(os.Args); err != nil {
-		fmt.Println("Error:", err)
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,81 @@
+package queue
+
+import (
+	"sort"
+	"time"
+
+	"github.com/Avik-creator/utils"
+)
+
+// WithAging makes waiting jobs gain one level of priority, towards High, for
+// every interval that has passed since their CreatedAt, but never beyond
+// ceiling. A job at or above ceiling already keeps its priority, as do jobs
+// with a zero CreatedAt. An interval of zero disables aging.
+//
+// Aging only changes which bucket is served next: an aged Low job competes
+// with the bucket of its effective priority, older job first, and keeps its
+// own priority otherwise.
+func WithAging(interval time.Duration, ceiling utils.Priority) Option {
+	return func(q *JobQueue) {
+		q.agingInterval = interval
+		q.agingCeiling = ceiling
+	}
+}
+
+// aged returns the effective priority of a job of priority p created at
+// createdAt.
+func (q *JobQueue) aged(p utils.Priority, createdAt, now time.Time) utils.Priority {
+	if q.agingInterval <= 0 || p <= q.agingCeiling || createdAt.IsZero() {
+		return p
+	}
+	steps := now.Sub(createdAt) / q.agingInterval
+	if steps <= 0 {
+		return p
+	}
+	if steps >= time.Duration(p-q.agingCeiling) {
+		return q.agingCeiling
+	}
+	return p - utils.Priority(steps)
+}
+
+// pick returns the priority of the bucket to serve next from the named queue,
+// whose non-empty priorities are ps. It is called with q.mu held.
+func (q *JobQueue) pick(name string, ps []utils.Priority) (utils.Priority, error) {
+	if q.agingInterval <= 0 {
+		return q.strategy.Next(name, ps), nil
+	}
+
+	// The head of a bucket is its oldest job, so it decides how far the
+	// bucket has aged. Where buckets age to the same level the one with the
+	// older head goes first.
+	type head struct {
+		priority  utils.Priority
+		createdAt time.Time
+	}
+	now := time.Now()
+	heads := make(map[utils.Priority]head, len(ps))
+	levels := make([]utils.Priority, 0, len(ps))
+	for _, p := range ps {
+		job, ok, err := q.storage.Peek(name, p)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			continue
+		}
+		level := q.aged(p, job.CreatedAt, now)
+		h, seen := heads[level]
+		if !seen {
+			levels = append(levels, level)
+		}
+		if !seen || job.CreatedAt.Before(h.createdAt) {
+			heads[level] = head{priority: p, createdAt: job.CreatedAt}
+		}
+	}
+	if len(levels) == 0 {
+		return ps[0], nil
+	}
+
+	sort.Slice(levels, func(i, j int) bool { return levels[i] < levels[j] })
+	return heads[q.strategy.Next(name, levels)].priority, nil
+}
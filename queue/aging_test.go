@@ -0,0 +1,73 @@
+package queue
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Avik-creator/utils"
+)
+
+func TestAgedPriority(t *testing.T) {
+	q := NewQueue(WithAging(time.Minute, utils.High))
+	now := time.Now()
+
+	tests := []struct {
+		name      string
+		priority  utils.Priority
+		createdAt time.Time
+		want      utils.Priority
+	}{
+		{"fresh", 10, now, 10},
+		{"one interval", 10, now.Add(-time.Minute), 9},
+		{"several intervals", 10, now.Add(-4*time.Minute - time.Second), 6},
+		{"capped at ceiling", 10, now.Add(-time.Hour), utils.High},
+		{"already at ceiling", utils.High, now.Add(-time.Hour), utils.High},
+		{"no CreatedAt", 10, time.Time{}, 10},
+		{"created in the future", 10, now.Add(time.Hour), 10},
+	}
+	for _, tt := range tests {
+		if got := q.aged(tt.priority, tt.createdAt, now); got != tt.want {
+			t.Errorf("%s: expected priority %d, got %d", tt.name, tt.want, got)
+		}
+	}
+
+	if got := NewQueue().aged(10, now.Add(-time.Hour), now); got != 10 {
+		t.Errorf("Expected no aging by default, got priority %d", got)
+	}
+}
+
+func TestAgingServesOldLowJobFirst(t *testing.T) {
+	q := NewQueue(WithAging(10*time.Millisecond, utils.High))
+	q.AddJob(utils.Job{ID: "old-low", Priority: utils.Low, CreatedAt: time.Now().Add(-time.Second)})
+	q.AddJob(utils.Job{ID: "new-high", Priority: utils.High, CreatedAt: time.Now()})
+
+	for _, want := range []string{"old-low", "new-high"} {
+		job, err := q.GetJob()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if job.ID != want {
+			t.Errorf("Expected %s, got %s", want, job.ID)
+		}
+		if job.ID == "old-low" && job.Priority != utils.Low {
+			t.Errorf("Expected aging to leave the job's priority alone, got %d", job.Priority)
+		}
+	}
+}
+
+func TestAgingRespectsCeiling(t *testing.T) {
+	q := NewQueue(WithAging(10*time.Millisecond, utils.Medium))
+	q.AddJob(utils.Job{ID: "old-low", Priority: utils.Low, CreatedAt: time.Now().Add(-time.Second)})
+	q.AddJob(utils.Job{ID: "new-medium", Priority: utils.Medium, CreatedAt: time.Now()})
+	q.AddJob(utils.Job{ID: "new-high", Priority: utils.High, CreatedAt: time.Now()})
+
+	for _, want := range []string{"new-high", "old-low", "new-medium"} {
+		job, err := q.GetJob()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if job.ID != want {
+			t.Errorf("Expected %s, got %s", want, job.ID)
+		}
+	}
+}
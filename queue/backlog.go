@@ -0,0 +1,109 @@
+package queue
+
+import (
+	"slices"
+	"time"
+
+	"github.com/Avik-creator/utils"
+)
+
+// Backlog is the jobs of one priority waiting to be dequeued.
+type Backlog struct {
+	Priority utils.Priority `json:"priority"`
+	Jobs     int            `json:"jobs"`
+	// Wait is how long the longest-waiting of them has been queued.
+	Wait time.Duration `json:"wait"`
+}
+
+// Backlog returns the jobs waiting in the named queues, or in the default
+// queue when none are given, per priority, most urgent first. Paused jobs are
+// left out, as no worker can take them.
+func (q *JobQueue) Backlog(queues ...string) ([]Backlog, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(queues) == 0 {
+		queues = []string{utils.DefaultQueue}
+	}
+	q.requeueExpired()
+	pauses, err := q.storage.Pauses()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	byPriority := make(map[utils.Priority]*Backlog)
+	var out []*Backlog
+	for _, name := range queues {
+		ps, err := q.storage.Priorities(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range unpaused(pauses, name, ps) {
+			jobs, wait, err := q.waiting(name, p, pausedTypes(pauses, name, p), now)
+			if err != nil {
+				return nil, err
+			}
+			if jobs == 0 {
+				continue
+			}
+			b, ok := byPriority[p]
+			if !ok {
+				b = &Backlog{Priority: p}
+				byPriority[p] = b
+				out = append(out, b)
+			}
+			b.Jobs += jobs
+			b.Wait = max(b.Wait, wait)
+		}
+	}
+
+	slices.SortFunc(out, func(a, b *Backlog) int { return int(a.Priority - b.Priority) })
+	backlog := make([]Backlog, len(out))
+	for i, b := range out {
+		backlog[i] = *b
+	}
+	return backlog, nil
+}
+
+// waiting counts the jobs of the given bucket whose Type is not in skipTypes
+// and returns how long the longest-waiting of them has been queued. It is
+// called with q.mu held.
+func (q *JobQueue) waiting(name string, p utils.Priority, skipTypes []string, now time.Time) (int, time.Duration, error) {
+	if len(skipTypes) == 0 {
+		n, err := q.storage.Len(name, p)
+		if err != nil || n == 0 {
+			return n, 0, err
+		}
+		head, ok, err := q.storage.Peek(name, p)
+		if err != nil || !ok {
+			return n, 0, err
+		}
+		return n, now.Sub(q.jobs.queuedSince(head)), nil
+	}
+
+	jobs, err := q.storage.List(name, p)
+	if err != nil {
+		return 0, 0, err
+	}
+	var n int
+	var wait time.Duration
+	for _, job := range jobs {
+		if slices.Contains(skipTypes, job.Type) {
+			continue
+		}
+		n++
+		wait = max(wait, now.Sub(q.jobs.queuedSince(job)))
+	}
+	return n, wait, nil
+}
+
+// queuedSince returns when job last entered the queue, or its CreatedAt if
+// the registry does not know.
+func (r *registry) queuedSince(job utils.Job) time.Time {
+	e, ok := r.jobs[job.ID]
+	if !ok || e.state != utils.Queued || len(e.history) == 0 {
+		return job.CreatedAt
+	}
+	return e.history[len(e.history)-1].At
+}
@@ -0,0 +1,49 @@
+package queue
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Avik-creator/utils"
+)
+
+func TestBacklog(t *testing.T) {
+	q := NewQueue()
+	q.AddJob(utils.Job{ID: "low1", Priority: utils.Low, CreatedAt: time.Now()})
+	q.AddJob(utils.Job{ID: "high1", Queue: "emails", Priority: utils.High, CreatedAt: time.Now()})
+	time.Sleep(20 * time.Millisecond)
+	q.AddJob(utils.Job{ID: "low2", Queue: "emails", Priority: utils.Low, CreatedAt: time.Now()})
+	q.AddJob(utils.Job{ID: "fax", Queue: "emails", Type: "fax", Priority: utils.High, CreatedAt: time.Now()})
+	q.Pause(Pause{Type: "fax"})
+
+	backlog, err := q.Backlog(utils.DefaultQueue, "emails")
+	if err != nil {
+		t.Fatalf("Backlog failed: %v", err)
+	}
+	if len(backlog) != 2 {
+		t.Fatalf("Expected a High and a Low backlog, got %v", backlog)
+	}
+	if b := backlog[0]; b.Priority != utils.High || b.Jobs != 1 || b.Wait < 20*time.Millisecond {
+		t.Errorf("Expected one High job waiting 20ms or more besides the paused one, got %+v", b)
+	}
+	if b := backlog[1]; b.Priority != utils.Low || b.Jobs != 2 || b.Wait < 20*time.Millisecond {
+		t.Errorf("Expected two Low jobs across both queues, got %+v", b)
+	}
+
+	q.Pause(Pause{Queue: "emails"})
+	if backlog, _ := q.Backlog("emails"); len(backlog) != 0 {
+		t.Errorf("Expected no backlog in a paused queue, got %v", backlog)
+	}
+}
+
+func TestBacklogWaitStartsWhenQueued(t *testing.T) {
+	q := NewQueue()
+	job := utils.Job{ID: "job1", Priority: utils.High, CreatedAt: time.Now().Add(-time.Hour)}
+	q.Schedule(job, time.Now())
+	q.AddJob(job)
+
+	backlog, _ := q.Backlog()
+	if len(backlog) != 1 || backlog[0].Wait > time.Minute {
+		t.Errorf("Expected the wait to count from when the job was queued, got %v", backlog)
+	}
+}
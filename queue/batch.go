@@ -0,0 +1,148 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Avik-creator/utils"
+)
+
+// AddJobs adds jobs to their queues under a single lock, so no other
+// operation sees part of the batch, and writes them to the log and the
+// storage in one go. Either every job is added or, on error, none is: a job
+// without an ID or with an invalid priority fails the whole batch with
+// ErrMissingID or ErrInvalidPriority, and a batch that does not fit within a
+// capacity limit fails with ErrQueueFull or, under the Block policy, waits
+// until it fits. A batch holding a job that has already expired fails with
+// ErrJobExpired and leaves that job alone, unlike AddJob. Jobs dropped to make
+// room for the batch stay dropped.
+func (q *JobQueue) AddJobs(jobs []utils.Job) error {
+	return q.AddJobsContext(context.Background(), jobs)
+}
+
+// AddJobsContext is AddJobs that gives up waiting for space under the Block
+// policy once ctx is done.
+func (q *JobQueue) AddJobsContext(ctx context.Context, jobs []utils.Job) error {
+	batch := make([]utils.Job, len(jobs))
+	for i, job := range jobs {
+		batch[i] = withQueue(job)
+		if err := checkID(batch[i]); err != nil {
+			return err
+		}
+		if err := checkPriority(batch[i]); err != nil {
+			return err
+		}
+	}
+	return q.block(ctx, errNoSpace, func() error {
+		return q.addJobs(batch)
+	})
+}
+
+func (q *JobQueue) addJobs(jobs []utils.Job) error {
+	if err := q.fits(jobs); err != nil {
+		return err
+	}
+	// Fail before expire discards or dead-letters anything.
+	now := time.Now()
+	for _, job := range jobs {
+		if job.Expired(now) {
+			return errExpired(job)
+		}
+	}
+
+	var added []utils.Job
+	for _, job := range jobs {
+		ok, err := q.admit(job, added, now)
+		if err != nil {
+			q.unwind(added)
+			return err
+		}
+		if ok {
+			added = append(added, job)
+			// Later jobs of the batch must see this one's unique key.
+			q.jobs.set(job, utils.Queued)
+		}
+	}
+	if len(added) == 0 {
+		return nil
+	}
+
+	if q.wal != nil {
+		if err := q.wal.append(record{Op: opAddBatch, Jobs: added}); err != nil {
+			q.unwind(added)
+			return err
+		}
+	}
+	if err := q.storage.EnqueueBatch(added, q.claims(added, now)); err != nil {
+		q.unwind(added)
+		return err
+	}
+	q.notify()
+	return nil
+}
+
+// unwind forgets the jobs admitted for a batch that failed before it was
+// stored. It is called with q.mu held.
+func (q *JobQueue) unwind(jobs []utils.Job) {
+	for _, job := range jobs {
+		q.jobs.forget(job.ID)
+	}
+}
+
+// GetJobs takes up to n jobs from the named queues, or from the default
+// queue when none are given, in the order n calls to GetJob would. It returns
+// ErrEmpty when there is no job at all.
+func (q *JobQueue) GetJobs(n int, queues ...string) ([]utils.Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var jobs []utils.Job
+	for len(jobs) < n {
+		job, err := q.getJob(queues)
+		if err != nil {
+			if len(jobs) > 0 && errors.Is(err, ErrEmpty) {
+				break
+			}
+			return jobs, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// LeaseJobs leases up to n jobs for timeout as n calls to Lease would. It
+// returns ErrEmpty when there is no job at all.
+func (q *JobQueue) LeaseJobs(n int, timeout time.Duration, queues ...string) ([]*Lease, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.leaseJobs(n, timeout, queues)
+}
+
+func (q *JobQueue) leaseJobs(n int, timeout time.Duration, queues []string) ([]*Lease, error) {
+	var leases []*Lease
+	for len(leases) < n {
+		l, err := q.lease(timeout, queues)
+		if err != nil {
+			if len(leases) > 0 && errors.Is(err, ErrEmpty) {
+				break
+			}
+			return leases, err
+		}
+		leases = append(leases, l)
+	}
+	return leases, nil
+}
+
+// DequeueLeases is LeaseJobs that blocks until at least one job is available
+// or ctx is done.
+func (q *JobQueue) DequeueLeases(ctx context.Context, n int, timeout time.Duration, queues ...string) ([]*Lease, error) {
+	var leases []*Lease
+	err := q.block(ctx, ErrEmpty, func() error {
+		var err error
+		leases, err = q.leaseJobs(n, timeout, queues)
+		return err
+	})
+	return leases, err
+}
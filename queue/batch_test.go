@@ -0,0 +1,123 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Avik-creator/utils"
+)
+
+func TestAddJobsAndGetJobs(t *testing.T) {
+	q := NewQueue()
+	now := time.Now()
+	err := q.AddJobs([]utils.Job{
+		{ID: "low", Priority: utils.Low, CreatedAt: now},
+		{ID: "high", Priority: utils.High, CreatedAt: now},
+		{ID: "medium", Priority: utils.Medium, CreatedAt: now},
+	})
+	if err != nil {
+		t.Fatalf("AddJobs failed: %v", err)
+	}
+
+	jobs, err := q.GetJobs(2)
+	if err != nil {
+		t.Fatalf("GetJobs failed: %v", err)
+	}
+	if len(jobs) != 2 || jobs[0].ID != "high" || jobs[1].ID != "medium" {
+		t.Errorf("Expected high and medium, got %v", jobs)
+	}
+
+	jobs, err = q.GetJobs(5)
+	if err != nil || len(jobs) != 1 || jobs[0].ID != "low" {
+		t.Errorf("Expected only low to be left, got %v (%v)", jobs, err)
+	}
+	if _, err := q.GetJobs(5); !errors.Is(err, ErrEmpty) {
+		t.Errorf("Expected ErrEmpty, got %v", err)
+	}
+}
+
+func TestAddJobsIsAllOrNothing(t *testing.T) {
+	q := NewQueue(WithCapacity(utils.DefaultQueue, 2, Reject))
+	now := time.Now()
+
+	err := q.AddJobs([]utils.Job{
+		{ID: "job1", Priority: utils.High, CreatedAt: now},
+		{ID: "bad", CreatedAt: now},
+	})
+	if !errors.Is(err, ErrInvalidPriority) {
+		t.Errorf("Expected ErrInvalidPriority, got %v", err)
+	}
+
+	err = q.AddJobs([]utils.Job{
+		{ID: "job1", Priority: utils.High, CreatedAt: now},
+		{ID: "job2", Priority: utils.High, CreatedAt: now},
+		{ID: "job3", Priority: utils.High, CreatedAt: now},
+	})
+	if !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Expected ErrQueueFull, got %v", err)
+	}
+
+	if _, err := q.GetJob(); !errors.Is(err, ErrEmpty) {
+		t.Errorf("Expected no job of a failed batch to be queued, got %v", err)
+	}
+}
+
+func TestAddJobsWithExpiredJob(t *testing.T) {
+	q := NewQueue(WithExpiry(DeadLetterExpired))
+	now := time.Now()
+
+	err := q.AddJobs([]utils.Job{
+		{ID: "job1", Priority: utils.High, CreatedAt: now},
+		{ID: "stale", Priority: utils.High, CreatedAt: now, ExpiresAt: now.Add(-time.Minute)},
+	})
+	if !errors.Is(err, ErrJobExpired) {
+		t.Fatalf("Expected ErrJobExpired, got %v", err)
+	}
+
+	if high, _, _, _ := q.GetAllJobs(); len(high) != 0 {
+		t.Errorf("Expected no job of the batch to be queued, got %v", high)
+	}
+	if dead, _, _, _ := q.GetAllDeadLetterJobs(); len(dead) != 0 {
+		t.Errorf("Expected the expired job not to be dead-lettered, got %v", dead)
+	}
+	if _, err := q.GetJobByID("stale"); !errors.Is(err, ErrUnknownJob) {
+		t.Errorf("Expected the expired job to be unknown, got %v", err)
+	}
+}
+
+func TestDequeueLeases(t *testing.T) {
+	q := NewQueue()
+
+	got := make(chan []*Lease)
+	go func() {
+		leases, err := q.DequeueLeases(context.Background(), 10, time.Minute)
+		if err != nil {
+			t.Errorf("DequeueLeases failed: %v", err)
+		}
+		got <- leases
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	var jobs []utils.Job
+	for i := 0; i < 3; i++ {
+		jobs = append(jobs, utils.Job{ID: fmt.Sprint(i), Priority: utils.Medium, CreatedAt: time.Now()})
+	}
+	q.AddJobs(jobs)
+
+	select {
+	case leases := <-got:
+		if len(leases) != 3 {
+			t.Fatalf("Expected the whole batch of 3 jobs, got %d", len(leases))
+		}
+		for _, l := range leases {
+			if err := q.Ack(l); err != nil {
+				t.Errorf("Ack of %s failed: %v", l.Job.ID, err)
+			}
+		}
+	case <-time.After(time.Second):
+		t.Fatal("DequeueLeases was not woken by AddJobs")
+	}
+}
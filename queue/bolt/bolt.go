@@ -0,0 +1,974 @@
+// Package bolt provides a queue.Storage kept in a single bbolt file. It is
+// pure Go and needs nothing beyond the file itself.
+package bolt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Avik-creator/queue"
+	"github.com/Avik-creator/utils"
+	bbolt "go.etcd.io/bbolt"
+)
+
+// The file holds one top-level bucket each for the live queue, the jobs in
+// flight and the dead-letter queue, each with a nested bucket per named queue
+// and, inside that, one per priority whose keys are the job's CreatedAt
+// followed by a sequence number (see jobKey), so cursor order is CreatedAt
+// order with ties broken FIFO. In-flight entries store a
+// queue.HeldJob rather than a bare job. The ids bucket maps a job ID to the
+// bucket and key that currently hold it, the results bucket maps a job ID to
+// its queue.Result, the deadlines bucket is keyed by the lease deadline of
+// each in-flight job followed by its ID (see deadlineKey), the pauses bucket
+// is keyed by the JSON of each queue.Pause, and the counts bucket holds the
+// number of jobs in each priority bucket, keyed by "<top>/<queue>/<priority>"
+// and kept up to date in the transaction that adds or removes the job. The
+// scheduled bucket maps a job ID to its queue.ScheduledJob, and the due
+// bucket is keyed by each schedule's time followed by the job ID, the same
+// way as deadlines, and the expiring bucket the same way by the ExpiresAt of
+// each scheduled job that has one. The claims bucket maps each unique key to its
+// queue.Claim.
+var (
+	queueBucket      = []byte("queue")
+	inFlightBucket   = []byte("in_flight")
+	deadLetterBucket = []byte("dead_letter")
+	idsBucket        = []byte("ids")
+	resultsBucket    = []byte("results")
+	deadlinesBucket  = []byte("deadlines")
+	pausesBucket     = []byte("pauses")
+	countsBucket     = []byte("counts")
+	scheduledBucket  = []byte("scheduled")
+	dueBucket        = []byte("due")
+	claimsBucket     = []byte("claims")
+	expiringBucket   = []byte("expiring")
+)
+
+var tops = [][]byte{queueBucket, inFlightBucket, deadLetterBucket}
+
+// keyLen is the length of the keys made by jobKey, and timeLen that of the
+// time they start with.
+const (
+	keyLen  = 20
+	timeLen = 12
+)
+
+// ErrLocked is returned by Open when another process has the file open.
+var ErrLocked = errors.New("bolt file is locked by another process")
+
+type Storage struct {
+	db *bbolt.DB
+}
+
+var _ queue.Storage = (*Storage)(nil)
+
+func Open(path string) (*Storage, error) {
+	db, err := bbolt.Open(path, 0o600, &bbolt.Options{Timeout: time.Second})
+	if errors.Is(err, bbolt.ErrTimeout) {
+		return nil, fmt.Errorf("open %s: %w", path, ErrLocked)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		for _, name := range [][]byte{queueBucket, inFlightBucket, deadLetterBucket, idsBucket, resultsBucket, deadlinesBucket, pausesBucket, countsBucket, scheduledBucket, dueBucket, expiringBucket, claimsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Storage{db: db}, nil
+}
+
+func (s *Storage) Close() error {
+	return s.db.Close()
+}
+
+func (s *Storage) Enqueue(job utils.Job) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return putJob(tx, queueBucket, job)
+	})
+}
+
+func (s *Storage) EnqueueBatch(jobs []utils.Job, claims []queue.Claim) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		for _, job := range jobs {
+			if err := putJob(tx, queueBucket, job); err != nil {
+				return err
+			}
+		}
+		return claim(tx, claims)
+	})
+}
+
+func (s *Storage) Dequeue(queueName string, priority utils.Priority, leaseUntil time.Time) (utils.Job, bool, error) {
+	return s.DequeueSkipping(queueName, priority, nil, leaseUntil)
+}
+
+func (s *Storage) DequeueSkipping(queueName string, priority utils.Priority, skipTypes []string, leaseUntil time.Time) (utils.Job, bool, error) {
+	var job utils.Job
+	var found bool
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		var err error
+		job, found, err = pop(tx, queueBucket, queueName, priority, skipTypes)
+		if err != nil || !found || leaseUntil.IsZero() {
+			return err
+		}
+		return putHeld(tx, queue.HeldJob{Job: job, Until: leaseUntil})
+	})
+	if err != nil {
+		return utils.Job{}, false, err
+	}
+	return job, found, nil
+}
+
+func (s *Storage) Job(id string) (utils.Job, bool, error) {
+	var job utils.Job
+	var found bool
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		loc := tx.Bucket(idsBucket).Get([]byte(id))
+		if loc == nil {
+			return nil
+		}
+		top, queueName, _, _, err := parseLocation(loc)
+		if err != nil {
+			return err
+		}
+		if string(top) == string(inFlightBucket) {
+			h, err := getHeld(tx, []byte(id))
+			job, found = h.Job, err == nil
+			return err
+		}
+		v, err := get(tx, loc)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(v, &job); err != nil {
+			return fmt.Errorf("decode job: %w", err)
+		}
+		job, found = withQueue(job, queueName), true
+		return nil
+	})
+	if err != nil {
+		return utils.Job{}, false, err
+	}
+	return job, found, nil
+}
+
+func (s *Storage) Peek(queueName string, priority utils.Priority) (utils.Job, bool, error) {
+	var job utils.Job
+	var found bool
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		b := bucket(tx, queueBucket, queueName, priority)
+		if b == nil {
+			return nil
+		}
+		k, v := b.Cursor().First()
+		if k == nil {
+			return nil
+		}
+		if err := json.Unmarshal(v, &job); err != nil {
+			return fmt.Errorf("decode job: %w", err)
+		}
+		job = withQueue(job, []byte(queueName))
+		found = true
+		return nil
+	})
+	if err != nil {
+		return utils.Job{}, false, err
+	}
+	return job, found, nil
+}
+
+func (s *Storage) Len(queueName string, priority utils.Priority) (int, error) {
+	var n int
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		if v := tx.Bucket(countsBucket).Get(countKey(queueBucket, []byte(queueName), priorityKey(priority))); v != nil {
+			n = int(binary.BigEndian.Uint64(v))
+		}
+		return nil
+	})
+	return n, err
+}
+
+func (s *Storage) Hold(job utils.Job, until time.Time) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return putHeld(tx, queue.HeldJob{Job: job, Until: until})
+	})
+}
+
+func (s *Storage) Ack(job utils.Job) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return remove(tx, job.ID, inFlightBucket)
+	})
+}
+
+func (s *Storage) ListInFlight() ([]queue.HeldJob, error) {
+	held := make([]queue.HeldJob, 0)
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		top := tx.Bucket(inFlightBucket)
+		return top.ForEachBucket(func(queueName []byte) error {
+			qb := top.Bucket(queueName)
+			return qb.ForEachBucket(func(priority []byte) error {
+				return qb.Bucket(priority).ForEach(func(k, v []byte) error {
+					var h queue.HeldJob
+					if err := json.Unmarshal(v, &h); err != nil {
+						return fmt.Errorf("decode job: %w", err)
+					}
+					h.Job = withQueue(h.Job, queueName)
+					held = append(held, h)
+					return nil
+				})
+			})
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(held, func(i, j int) bool { return held[i].Until.Before(held[j].Until) })
+	return held, nil
+}
+
+func (s *Storage) ListExpired(now time.Time) ([]queue.HeldJob, error) {
+	held := make([]queue.HeldJob, 0)
+	end := timeKey(now)
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		c := tx.Bucket(deadlinesBucket).Cursor()
+		for k, _ := c.First(); k != nil && bytes.Compare(k[:timeLen], end) <= 0; k, _ = c.Next() {
+			h, err := getHeld(tx, k[timeLen:])
+			if err != nil {
+				return err
+			}
+			held = append(held, h)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return held, nil
+}
+
+func (s *Storage) NextDeadline() (time.Time, bool, error) {
+	var until time.Time
+	var ok bool
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		k, _ := tx.Bucket(deadlinesBucket).Cursor().First()
+		if k == nil {
+			return nil
+		}
+		h, err := getHeld(tx, k[timeLen:])
+		until, ok = h.Until, true
+		return err
+	})
+	return until, ok, err
+}
+
+// getHeld returns the in-flight job with the given ID.
+func getHeld(tx *bbolt.Tx, id []byte) (queue.HeldJob, error) {
+	loc := tx.Bucket(idsBucket).Get(id)
+	if loc == nil {
+		return queue.HeldJob{}, fmt.Errorf("missing in-flight job %s", id)
+	}
+	v, err := get(tx, loc)
+	if err != nil {
+		return queue.HeldJob{}, err
+	}
+	_, queueName, _, _, _ := parseLocation(loc)
+	var h queue.HeldJob
+	if err := json.Unmarshal(v, &h); err != nil {
+		return queue.HeldJob{}, fmt.Errorf("decode job: %w", err)
+	}
+	h.Job = withQueue(h.Job, queueName)
+	return h, nil
+}
+
+func (s *Storage) Remove(job utils.Job) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		if err := unschedule(tx, job.ID); err != nil {
+			return err
+		}
+		return remove(tx, job.ID, queueBucket)
+	})
+}
+
+func (s *Storage) MoveToDeadLetter(job utils.Job) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return putJob(tx, deadLetterBucket, job)
+	})
+}
+
+func (s *Storage) DequeueDeadLetter(queueName string, priority utils.Priority) (utils.Job, bool, error) {
+	var job utils.Job
+	var found bool
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		var err error
+		job, found, err = pop(tx, deadLetterBucket, queueName, priority, nil)
+		return err
+	})
+	if err != nil {
+		return utils.Job{}, false, err
+	}
+	return job, found, nil
+}
+
+func (s *Storage) List(queueName string, priority utils.Priority) ([]utils.Job, error) {
+	return s.list(queueBucket, queueName, priority)
+}
+
+func (s *Storage) ListDeadLetter(queueName string, priority utils.Priority) ([]utils.Job, error) {
+	return s.list(deadLetterBucket, queueName, priority)
+}
+
+func (s *Storage) Priorities(queueName string) ([]utils.Priority, error) {
+	return s.priorities(queueBucket, queueName)
+}
+
+func (s *Storage) DeadLetterPriorities(queueName string) ([]utils.Priority, error) {
+	return s.priorities(deadLetterBucket, queueName)
+}
+
+func (s *Storage) priorities(top []byte, queueName string) ([]utils.Priority, error) {
+	ps := make([]utils.Priority, 0)
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		qb := tx.Bucket(top).Bucket([]byte(queueName))
+		if qb == nil {
+			return nil
+		}
+		return qb.ForEachBucket(func(name []byte) error {
+			if k, _ := qb.Bucket(name).Cursor().First(); k == nil {
+				return nil
+			}
+			p, err := parsePriority(name)
+			if err != nil {
+				return err
+			}
+			ps = append(ps, p)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(ps, func(i, j int) bool { return ps[i] < ps[j] })
+	return ps, nil
+}
+
+func (s *Storage) Queues() ([]string, error) {
+	seen := make(map[string]bool)
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		for _, top := range tops {
+			tb := tx.Bucket(top)
+			err := tb.ForEachBucket(func(queueName []byte) error {
+				qb := tb.Bucket(queueName)
+				return qb.ForEachBucket(func(priority []byte) error {
+					if k, _ := qb.Bucket(priority).Cursor().First(); k != nil {
+						seen[string(queueName)] = true
+					}
+					return nil
+				})
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s *Storage) SaveResult(r queue.Result) error {
+	v, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(resultsBucket).Put([]byte(r.JobID), v)
+	})
+}
+
+func (s *Storage) Result(jobID string) (queue.Result, bool, error) {
+	var r queue.Result
+	var found bool
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		v := tx.Bucket(resultsBucket).Get([]byte(jobID))
+		if v == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(v, &r)
+	})
+	if err != nil {
+		return queue.Result{}, false, fmt.Errorf("decode result: %w", err)
+	}
+	return r, found, nil
+}
+
+func (s *Storage) Results() ([]queue.Result, error) {
+	var results []queue.Result
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(resultsBucket).ForEach(func(_, v []byte) error {
+			var r queue.Result
+			if err := json.Unmarshal(v, &r); err != nil {
+				return fmt.Errorf("decode result: %w", err)
+			}
+			results = append(results, r)
+			return nil
+		})
+	})
+	sort.Slice(results, func(i, j int) bool { return results[i].CreatedAt.Before(results[j].CreatedAt) })
+	return results, err
+}
+
+func (s *Storage) PruneResults(now time.Time) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(resultsBucket)
+		var expired [][]byte
+		err := b.ForEach(func(k, v []byte) error {
+			var r queue.Result
+			if err := json.Unmarshal(v, &r); err != nil {
+				return fmt.Errorf("decode result: %w", err)
+			}
+			if !r.ExpiresAt.After(now) {
+				expired = append(expired, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range expired {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Storage) AddPause(p queue.Pause) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(pausesBucket).Put(pauseKey(p), nil)
+	})
+}
+
+func (s *Storage) RemovePause(p queue.Pause) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(pausesBucket).Delete(pauseKey(p))
+	})
+}
+
+func (s *Storage) Pauses() ([]queue.Pause, error) {
+	pauses := make([]queue.Pause, 0)
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(pausesBucket).ForEach(func(k, _ []byte) error {
+			var p queue.Pause
+			if err := json.Unmarshal(k, &p); err != nil {
+				return fmt.Errorf("decode pause: %w", err)
+			}
+			pauses = append(pauses, p)
+			return nil
+		})
+	})
+	return pauses, err
+}
+
+func (s *Storage) Schedule(sj queue.ScheduledJob, claims []queue.Claim) error {
+	if !sj.Job.Priority.Valid() {
+		return fmt.Errorf("%w %d for job %s", queue.ErrInvalidPriority, sj.Job.Priority, sj.Job.ID)
+	}
+	v, err := json.Marshal(sj)
+	if err != nil {
+		return fmt.Errorf("encode scheduled job: %w", err)
+	}
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		if err := unschedule(tx, sj.Job.ID); err != nil {
+			return err
+		}
+		if err := tx.Bucket(dueBucket).Put(deadlineKey(sj.ScheduleTime, sj.Job.ID), []byte{}); err != nil {
+			return err
+		}
+		if !sj.Job.ExpiresAt.IsZero() {
+			if err := tx.Bucket(expiringBucket).Put(deadlineKey(sj.Job.ExpiresAt, sj.Job.ID), []byte{}); err != nil {
+				return err
+			}
+		}
+		if err := tx.Bucket(scheduledBucket).Put([]byte(sj.Job.ID), v); err != nil {
+			return err
+		}
+		return claim(tx, claims)
+	})
+}
+
+func (s *Storage) PruneClaims(now time.Time) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(claimsBucket)
+		var stale [][]byte
+		err := b.ForEach(func(k, v []byte) error {
+			var c queue.Claim
+			if err := json.Unmarshal(v, &c); err != nil {
+				return fmt.Errorf("decode claim: %w", err)
+			}
+			if !c.Held(now, live(tx, c.JobID)) {
+				stale = append(stale, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range stale {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// claim takes claims in tx, failing with queue.ErrDuplicateJob when one of
+// their keys is held by another job. It is called once the jobs themselves
+// are written, so that a job of the same write counts as live.
+func claim(tx *bbolt.Tx, claims []queue.Claim) error {
+	b := tx.Bucket(claimsBucket)
+	for _, c := range claims {
+		if v := b.Get([]byte(c.Key)); v != nil {
+			var held queue.Claim
+			if err := json.Unmarshal(v, &held); err != nil {
+				return fmt.Errorf("decode claim: %w", err)
+			}
+			if held.JobID == c.JobID {
+				continue
+			}
+			if held.Held(c.At, live(tx, held.JobID)) {
+				return fmt.Errorf("%w: job %s has the unique key %q of job %s", queue.ErrDuplicateJob, c.JobID, c.Key, held.JobID)
+			}
+		}
+		v, err := json.Marshal(c)
+		if err != nil {
+			return fmt.Errorf("encode claim: %w", err)
+		}
+		if err := b.Put([]byte(c.Key), v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// live reports whether the job with the given ID is scheduled, queued or in
+// flight.
+func live(tx *bbolt.Tx, id string) bool {
+	if tx.Bucket(scheduledBucket).Get([]byte(id)) != nil {
+		return true
+	}
+	loc := tx.Bucket(idsBucket).Get([]byte(id))
+	if loc == nil {
+		return false
+	}
+	top, _, _, _, err := parseLocation(loc)
+	return err == nil && string(top) != string(deadLetterBucket)
+}
+
+func (s *Storage) ScheduledJob(id string) (queue.ScheduledJob, bool, error) {
+	var sj queue.ScheduledJob
+	var found bool
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		v := tx.Bucket(scheduledBucket).Get([]byte(id))
+		if v == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(v, &sj)
+	})
+	if err != nil {
+		return queue.ScheduledJob{}, false, fmt.Errorf("decode scheduled job: %w", err)
+	}
+	return sj, found, nil
+}
+
+func (s *Storage) ScheduledJobs() ([]queue.ScheduledJob, error) {
+	var jobs []queue.ScheduledJob
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		var err error
+		jobs, err = scheduled(tx, dueBucket, nil)
+		return err
+	})
+	return jobs, err
+}
+
+func (s *Storage) TakeDue(now time.Time) ([]queue.ScheduledJob, error) {
+	var due []queue.ScheduledJob
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		var err error
+		if due, err = scheduled(tx, dueBucket, timeKey(now)); err != nil {
+			return err
+		}
+		expired, err := scheduled(tx, expiringBucket, timeKey(now))
+		if err != nil {
+			return err
+		}
+		for _, sj := range expired {
+			if !slices.ContainsFunc(due, func(d queue.ScheduledJob) bool { return d.Job.ID == sj.Job.ID }) {
+				due = append(due, sj)
+			}
+		}
+		sort.SliceStable(due, func(i, j int) bool { return due[i].ScheduleTime.Before(due[j].ScheduleTime) })
+		for _, sj := range due {
+			if err := unschedule(tx, sj.Job.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return due, nil
+}
+
+// scheduled returns the schedules in index, the due or expiring bucket, in
+// its order, stopping after the ones keyed by a time no later than end when
+// end is non-nil.
+func scheduled(tx *bbolt.Tx, index, end []byte) ([]queue.ScheduledJob, error) {
+	jobs := make([]queue.ScheduledJob, 0)
+	b := tx.Bucket(scheduledBucket)
+	c := tx.Bucket(index).Cursor()
+	for k, _ := c.First(); k != nil && (end == nil || bytes.Compare(k[:timeLen], end) <= 0); k, _ = c.Next() {
+		var sj queue.ScheduledJob
+		if err := json.Unmarshal(b.Get(k[timeLen:]), &sj); err != nil {
+			return nil, fmt.Errorf("decode scheduled job %s: %w", k[timeLen:], err)
+		}
+		jobs = append(jobs, sj)
+	}
+	return jobs, nil
+}
+
+// pauseKey encodes p as the key of its entry in the pauses bucket.
+func pauseKey(p queue.Pause) []byte {
+	k, _ := json.Marshal(p)
+	return k
+}
+
+// bucket returns the priority bucket of the named queue under top, or nil if
+// it does not exist.
+func bucket(tx *bbolt.Tx, top []byte, queueName string, priority utils.Priority) *bbolt.Bucket {
+	qb := tx.Bucket(top).Bucket([]byte(queueName))
+	if qb == nil {
+		return nil
+	}
+	return qb.Bucket(priorityKey(priority))
+}
+
+// pop removes the first job of a priority bucket whose type is not in
+// skipTypes. Callers run it inside their own read-write transaction, so once
+// that commits the job is gone from the bucket for good.
+func pop(tx *bbolt.Tx, top []byte, queueName string, priority utils.Priority, skipTypes []string) (utils.Job, bool, error) {
+	b := bucket(tx, top, queueName, priority)
+	if b == nil {
+		return utils.Job{}, false, nil
+	}
+
+	var job utils.Job
+	c := b.Cursor()
+	k, v := c.First()
+	for ; k != nil; k, v = c.Next() {
+		job = utils.Job{}
+		if err := json.Unmarshal(v, &job); err != nil {
+			return utils.Job{}, false, fmt.Errorf("decode job: %w", err)
+		}
+		if !slices.Contains(skipTypes, job.Type) {
+			break
+		}
+	}
+	if k == nil {
+		return utils.Job{}, false, nil
+	}
+	if err := b.Delete(k); err != nil {
+		return utils.Job{}, false, err
+	}
+	if err := count(tx, top, []byte(queueName), priorityKey(priority), -1); err != nil {
+		return utils.Job{}, false, err
+	}
+	return withQueue(job, []byte(queueName)), true, tx.Bucket(idsBucket).Delete([]byte(job.ID))
+}
+
+func (s *Storage) list(top []byte, queueName string, priority utils.Priority) ([]utils.Job, error) {
+	jobs := make([]utils.Job, 0)
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		b := bucket(tx, top, queueName, priority)
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			var job utils.Job
+			if err := json.Unmarshal(v, &job); err != nil {
+				return fmt.Errorf("decode job: %w", err)
+			}
+			jobs = append(jobs, withQueue(job, []byte(queueName)))
+			return nil
+		})
+	})
+	return jobs, err
+}
+
+// withQueue fills in the queue of a job stored before jobs carried one.
+func withQueue(job utils.Job, queueName []byte) utils.Job {
+	if job.Queue == "" {
+		job.Queue = string(queueName)
+	}
+	return job
+}
+
+// putJob stores job under top, taking it off the schedule.
+func putJob(tx *bbolt.Tx, top []byte, job utils.Job) error {
+	value, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("encode job: %w", err)
+	}
+	if err := unschedule(tx, job.ID); err != nil {
+		return err
+	}
+	return put(tx, top, job, value)
+}
+
+func putHeld(tx *bbolt.Tx, h queue.HeldJob) error {
+	value, err := json.Marshal(h)
+	if err != nil {
+		return fmt.Errorf("encode job: %w", err)
+	}
+	if err := put(tx, inFlightBucket, h.Job, value); err != nil {
+		return err
+	}
+	return tx.Bucket(deadlinesBucket).Put(deadlineKey(h.Until, h.Job.ID), []byte{})
+}
+
+// put stores value for job under top, replacing whatever entry the file
+// already holds for the job's ID.
+func put(tx *bbolt.Tx, top []byte, job utils.Job, value []byte) error {
+	if !job.Priority.Valid() {
+		return fmt.Errorf("%w %d for job %s", queue.ErrInvalidPriority, job.Priority, job.ID)
+	}
+
+	if err := remove(tx, job.ID, nil); err != nil {
+		return err
+	}
+
+	qb, err := tx.Bucket(top).CreateBucketIfNotExists([]byte(job.Queue))
+	if err != nil {
+		return err
+	}
+	b, err := qb.CreateBucketIfNotExists(priorityKey(job.Priority))
+	if err != nil {
+		return err
+	}
+	seq, err := b.NextSequence()
+	if err != nil {
+		return err
+	}
+
+	key := jobKey(job.CreatedAt, seq)
+	if err := b.Put(key, value); err != nil {
+		return err
+	}
+	if err := count(tx, top, []byte(job.Queue), priorityKey(job.Priority), 1); err != nil {
+		return err
+	}
+	return tx.Bucket(idsBucket).Put([]byte(job.ID), location(top, job.Queue, job.Priority, key))
+}
+
+// remove deletes the job with the given ID. When top is non-nil the job is
+// only removed if it lives under that top-level bucket.
+func remove(tx *bbolt.Tx, id string, top []byte) error {
+	ids := tx.Bucket(idsBucket)
+	loc := ids.Get([]byte(id))
+	if loc == nil {
+		return nil
+	}
+	loc = append([]byte(nil), loc...)
+
+	locTop, queueName, priority, key, err := parseLocation(loc)
+	if err != nil {
+		return err
+	}
+	if top != nil && string(locTop) != string(top) {
+		return nil
+	}
+	if qb := tx.Bucket(locTop).Bucket(queueName); qb != nil {
+		if b := qb.Bucket(priority); b != nil && b.Get(key) != nil {
+			if string(locTop) == string(inFlightBucket) {
+				if err := forgetDeadline(tx, id, b.Get(key)); err != nil {
+					return err
+				}
+			}
+			if err := b.Delete(key); err != nil {
+				return err
+			}
+			if err := count(tx, locTop, queueName, priority, -1); err != nil {
+				return err
+			}
+		}
+	}
+	return ids.Delete([]byte(id))
+}
+
+// get returns the value stored at loc.
+func get(tx *bbolt.Tx, loc []byte) ([]byte, error) {
+	top, queueName, priority, key, err := parseLocation(loc)
+	if err != nil {
+		return nil, err
+	}
+	if qb := tx.Bucket(top).Bucket(queueName); qb != nil {
+		if b := qb.Bucket(priority); b != nil {
+			if v := b.Get(key); v != nil {
+				return v, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("missing job at %q", loc)
+}
+
+// forgetDeadline removes the deadline of the in-flight job with the given ID
+// and stored value from the deadlines bucket.
+func forgetDeadline(tx *bbolt.Tx, id string, value []byte) error {
+	if value == nil {
+		return nil
+	}
+	var h queue.HeldJob
+	if err := json.Unmarshal(value, &h); err != nil {
+		return fmt.Errorf("decode job: %w", err)
+	}
+	return tx.Bucket(deadlinesBucket).Delete(deadlineKey(h.Until, id))
+}
+
+// unschedule forgets the schedule of the job with the given ID, if any.
+func unschedule(tx *bbolt.Tx, id string) error {
+	b := tx.Bucket(scheduledBucket)
+	v := b.Get([]byte(id))
+	if v == nil {
+		return nil
+	}
+	var sj queue.ScheduledJob
+	if err := json.Unmarshal(v, &sj); err != nil {
+		return fmt.Errorf("decode scheduled job: %w", err)
+	}
+	if err := tx.Bucket(dueBucket).Delete(deadlineKey(sj.ScheduleTime, id)); err != nil {
+		return err
+	}
+	if !sj.Job.ExpiresAt.IsZero() {
+		if err := tx.Bucket(expiringBucket).Delete(deadlineKey(sj.Job.ExpiresAt, id)); err != nil {
+			return err
+		}
+	}
+	return b.Delete([]byte(id))
+}
+
+// count adds delta to the number of jobs in the given priority bucket.
+func count(tx *bbolt.Tx, top, queueName, priority []byte, delta int) error {
+	counts := tx.Bucket(countsBucket)
+	key := countKey(top, queueName, priority)
+	var n uint64
+	if v := counts.Get(key); v != nil {
+		n = binary.BigEndian.Uint64(v)
+	}
+	n += uint64(delta)
+	if n == 0 {
+		return counts.Delete(key)
+	}
+	v := make([]byte, 8)
+	binary.BigEndian.PutUint64(v, n)
+	return counts.Put(key, v)
+}
+
+// countKey is "<top>/<queue>/<priority>". As in location, only the queue name
+// may contain slashes.
+func countKey(top, queueName, priority []byte) []byte {
+	key := append([]byte{}, top...)
+	key = append(key, '/')
+	key = append(key, queueName...)
+	key = append(key, '/')
+	return append(key, priority...)
+}
+
+func priorityKey(p utils.Priority) []byte {
+	return []byte(strconv.Itoa(int(p)))
+}
+
+func parsePriority(name []byte) (utils.Priority, error) {
+	p, err := strconv.Atoi(string(name))
+	if err != nil {
+		return 0, fmt.Errorf("corrupt priority bucket %q", name)
+	}
+	return utils.Priority(p), nil
+}
+
+// jobKey encodes CreatedAt as sign-flipped big-endian Unix seconds and
+// nanoseconds, followed by seq, so that keys sort by CreatedAt and then seq.
+func jobKey(createdAt time.Time, seq uint64) []byte {
+	key := make([]byte, keyLen)
+	copy(key, timeKey(createdAt))
+	binary.BigEndian.PutUint64(key[timeLen:], seq)
+	return key
+}
+
+// deadlineKey is timeKey(until) followed by id.
+func deadlineKey(until time.Time, id string) []byte {
+	return append(timeKey(until), id...)
+}
+
+// timeKey encodes t so that keys sort by time.
+func timeKey(t time.Time) []byte {
+	key := make([]byte, timeLen)
+	binary.BigEndian.PutUint64(key, uint64(t.Unix())^1<<63)
+	binary.BigEndian.PutUint32(key[8:], uint32(t.Nanosecond()))
+	return key
+}
+
+// location encodes where a job lives as
+// "<top>/<queue>/<priority>/<key>". Queue names may contain slashes, but top
+// and priority never do.
+func location(top []byte, queueName string, p utils.Priority, key []byte) []byte {
+	loc := append([]byte{}, top...)
+	loc = append(loc, '/')
+	loc = append(loc, queueName...)
+	loc = append(loc, '/')
+	loc = append(loc, priorityKey(p)...)
+	loc = append(loc, '/')
+	return append(loc, key...)
+}
+
+func parseLocation(loc []byte) (top, queueName, priority, key []byte, err error) {
+	corrupt := fmt.Errorf("corrupt job location %q", loc)
+	if len(loc) < keyLen+6 {
+		return nil, nil, nil, nil, corrupt
+	}
+	key = loc[len(loc)-keyLen:]
+	rest := loc[:len(loc)-keyLen-1]
+
+	first := bytes.IndexByte(rest, '/')
+	last := bytes.LastIndexByte(rest, '/')
+	if first < 0 || last == first {
+		return nil, nil, nil, nil, corrupt
+	}
+	return rest[:first], rest[first+1 : last], rest[last+1:], key, nil
+}
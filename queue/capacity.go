@@ -0,0 +1,237 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/Avik-creator/utils"
+)
+
+// ErrQueueFull is returned by AddJob when a capacity limit is reached and its
+// overflow policy does not make room for the job.
+var ErrQueueFull = errors.New("queue is full")
+
+// errNoSpace makes a blocking add wait for a job to leave the queue.
+var errNoSpace = errors.New("waiting for space")
+
+// Overflow says what AddJob does when a capacity limit is reached.
+type Overflow int
+
+const (
+	// Reject fails AddJob with ErrQueueFull.
+	Reject Overflow = iota
+	// Block makes AddJob wait until a job leaves the queue, or until the
+	// context passed to AddJobContext is done.
+	Block
+	// DropOldest discards the oldest queued job within the limit to make
+	// room.
+	DropOldest
+	// DropLowestPriority discards the oldest job of the least urgent
+	// priority within the limit to make room. A job less urgent than every
+	// queued job is rejected with ErrQueueFull instead.
+	DropLowestPriority
+)
+
+func (o Overflow) String() string {
+	switch o {
+	case Reject:
+		return "reject"
+	case Block:
+		return "block"
+	case DropOldest:
+		return "drop-oldest"
+	case DropLowestPriority:
+		return "drop-lowest-priority"
+	}
+	return fmt.Sprintf("Overflow(%d)", int(o))
+}
+
+// limit caps the queued jobs of a queue, or of one of its priorities when
+// priority is non-zero.
+type limit struct {
+	queue    string
+	priority utils.Priority
+	max      int
+	overflow Overflow
+}
+
+// WithCapacity limits the named queue to max queued jobs across all of its
+// priorities. Only AddJob is limited: jobs coming back from a lease or a
+// retry were already accepted and are always requeued.
+func WithCapacity(queue string, max int, overflow Overflow) Option {
+	return func(q *JobQueue) {
+		q.limits = append(q.limits, limit{queue: queue, max: max, overflow: overflow})
+	}
+}
+
+// WithPriorityCapacity limits one priority of the named queue to max queued
+// jobs. It can be combined with WithCapacity, in which case a job must fit
+// within both.
+func WithPriorityCapacity(queue string, priority utils.Priority, max int, overflow Overflow) Option {
+	return func(q *JobQueue) {
+		q.limits = append(q.limits, limit{queue: queue, priority: priority, max: max, overflow: overflow})
+	}
+}
+
+// AddJobContext is AddJob that gives up waiting for space under the Block
+// policy once ctx is done.
+func (q *JobQueue) AddJobContext(ctx context.Context, job utils.Job) error {
+	job = withQueue(job)
+	if err := checkID(job); err != nil {
+		return err
+	}
+	if err := checkPriority(job); err != nil {
+		return err
+	}
+	return q.block(ctx, errNoSpace, func() error {
+		return q.addJob(job)
+	})
+}
+
+// makeRoom checks job against every limit that applies to it, dropping jobs
+// where the overflow policy says so. pending are jobs of the same batch that
+// are about to be stored and count towards the limits. It returns errNoSpace
+// when the job has to wait under the Block policy. It is called with q.mu
+// held.
+func (q *JobQueue) makeRoom(job utils.Job, pending []utils.Job) error {
+	for _, l := range q.limits {
+		if !l.applies(job) {
+			continue
+		}
+		for {
+			n, err := q.count(l)
+			if err != nil {
+				return err
+			}
+			for _, p := range pending {
+				if l.applies(p) {
+					n++
+				}
+			}
+			if n < l.max {
+				break
+			}
+			switch l.overflow {
+			case Block:
+				return errNoSpace
+			case DropOldest, DropLowestPriority:
+				victim, ok, err := q.victim(l, job)
+				if err != nil {
+					return err
+				}
+				if !ok {
+					return fmt.Errorf("%w: %s holds %d jobs", ErrQueueFull, job.Queue, n)
+				}
+				if err := q.drop(victim); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("%w: %s holds %d jobs", ErrQueueFull, job.Queue, n)
+			}
+		}
+	}
+	return nil
+}
+
+// fits checks that a batch of jobs fits within every Reject and Block limit
+// as a whole, returning errNoSpace when it has to wait under the Block
+// policy. Limits that drop jobs are left to makeRoom. It is called with q.mu
+// held.
+func (q *JobQueue) fits(jobs []utils.Job) error {
+	for _, l := range q.limits {
+		if l.overflow != Reject && l.overflow != Block {
+			continue
+		}
+		in := 0
+		for _, job := range jobs {
+			if l.applies(job) {
+				in++
+			}
+		}
+		if in == 0 {
+			continue
+		}
+		n, err := q.count(l)
+		if err != nil {
+			return err
+		}
+		if n+in <= l.max {
+			continue
+		}
+		if l.overflow == Block && in <= l.max {
+			return errNoSpace
+		}
+		return fmt.Errorf("%w: %s holds %d jobs, %d more do not fit", ErrQueueFull, l.queue, n, in)
+	}
+	return nil
+}
+
+// applies reports whether l limits the bucket job goes into.
+func (l limit) applies(job utils.Job) bool {
+	return l.queue == job.Queue && (l.priority == 0 || l.priority == job.Priority)
+}
+
+// count returns the number of queued jobs l applies to.
+func (q *JobQueue) count(l limit) (int, error) {
+	if l.priority != 0 {
+		return q.storage.Len(l.queue, l.priority)
+	}
+	ps, err := q.storage.Priorities(l.queue)
+	if err != nil {
+		return 0, err
+	}
+	total := 0
+	for _, p := range ps {
+		n, err := q.storage.Len(l.queue, p)
+		if err != nil {
+			return 0, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// victim returns the queued job to drop to make room for job under l.
+func (q *JobQueue) victim(l limit, job utils.Job) (utils.Job, bool, error) {
+	if l.priority != 0 {
+		return q.storage.Peek(l.queue, l.priority)
+	}
+	ps, err := q.storage.Priorities(l.queue)
+	if err != nil || len(ps) == 0 {
+		return utils.Job{}, false, err
+	}
+	if l.overflow == DropLowestPriority {
+		lowest := ps[len(ps)-1]
+		if job.Priority > lowest {
+			return utils.Job{}, false, nil
+		}
+		return q.storage.Peek(l.queue, lowest)
+	}
+
+	var oldest utils.Job
+	found := false
+	for _, p := range ps {
+		head, ok, err := q.storage.Peek(l.queue, p)
+		if err != nil {
+			return utils.Job{}, false, err
+		}
+		if ok && (!found || head.CreatedAt.Before(oldest.CreatedAt)) {
+			oldest, found = head, true
+		}
+	}
+	return oldest, found, nil
+}
+
+func (q *JobQueue) drop(job utils.Job) error {
+	if err := q.record(opRemove, job); err != nil {
+		return err
+	}
+	if err := q.storage.Remove(job); err != nil {
+		return err
+	}
+	q.jobs.forget(job.ID)
+	log.Printf("queue: dropped job %s from full queue %s", job.ID, job.Queue)
+	return nil
+}
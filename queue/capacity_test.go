@@ -0,0 +1,135 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Avik-creator/utils"
+)
+
+// ids drains the default queue and returns the IDs of its jobs in dequeue
+// order.
+func ids(t *testing.T, q *JobQueue) []string {
+	t.Helper()
+	var got []string
+	for {
+		job, err := q.GetJob()
+		if errors.Is(err, ErrEmpty) {
+			return got
+		}
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		got = append(got, job.ID)
+	}
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestCapacityReject(t *testing.T) {
+	q := NewQueue(WithCapacity(utils.DefaultQueue, 2, Reject))
+	now := time.Now()
+
+	q.AddJob(utils.Job{ID: "job1", Priority: utils.High, CreatedAt: now})
+	q.AddJob(utils.Job{ID: "job2", Priority: utils.Low, CreatedAt: now})
+	if err := q.AddJob(utils.Job{ID: "job3", Priority: utils.High, CreatedAt: now}); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("Expected ErrQueueFull, got %v", err)
+	}
+	if err := q.AddJob(utils.Job{ID: "other", Queue: "other", Priority: utils.High, CreatedAt: now}); err != nil {
+		t.Errorf("Expected other queues not to be limited, got %v", err)
+	}
+
+	q.GetJob()
+	if err := q.AddJob(utils.Job{ID: "job3", Priority: utils.High, CreatedAt: now}); err != nil {
+		t.Errorf("Expected room after a dequeue, got %v", err)
+	}
+}
+
+func TestPriorityCapacity(t *testing.T) {
+	q := NewQueue(WithPriorityCapacity(utils.DefaultQueue, utils.Low, 1, Reject))
+	now := time.Now()
+
+	q.AddJob(utils.Job{ID: "low1", Priority: utils.Low, CreatedAt: now})
+	if err := q.AddJob(utils.Job{ID: "low2", Priority: utils.Low, CreatedAt: now}); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Expected ErrQueueFull for a second Low job, got %v", err)
+	}
+	if err := q.AddJob(utils.Job{ID: "high", Priority: utils.High, CreatedAt: now}); err != nil {
+		t.Errorf("Expected High not to be limited, got %v", err)
+	}
+}
+
+func TestCapacityBlock(t *testing.T) {
+	q := NewQueue(WithCapacity(utils.DefaultQueue, 1, Block))
+	q.AddJob(utils.Job{ID: "job1", Priority: utils.High, CreatedAt: time.Now()})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := q.AddJobContext(ctx, utils.Job{ID: "job2", Priority: utils.High, CreatedAt: time.Now()}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected context.DeadlineExceeded while the queue is full, got %v", err)
+	}
+
+	added := make(chan error)
+	go func() {
+		added <- q.AddJob(utils.Job{ID: "job2", Priority: utils.High, CreatedAt: time.Now()})
+	}()
+	select {
+	case err := <-added:
+		t.Fatalf("Expected AddJob to block while the queue is full, got %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	q.GetJob()
+	select {
+	case err := <-added:
+		if err != nil {
+			t.Errorf("AddJob failed: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("AddJob was not woken when space freed up")
+	}
+}
+
+func TestCapacityDropOldest(t *testing.T) {
+	q := NewQueue(WithCapacity(utils.DefaultQueue, 2, DropOldest))
+	now := time.Now()
+
+	q.AddJob(utils.Job{ID: "low-old", Priority: utils.Low, CreatedAt: now.Add(-time.Minute)})
+	q.AddJob(utils.Job{ID: "high", Priority: utils.High, CreatedAt: now})
+	if err := q.AddJob(utils.Job{ID: "medium", Priority: utils.Medium, CreatedAt: now}); err != nil {
+		t.Fatalf("AddJob failed: %v", err)
+	}
+
+	if got := ids(t, q); !equal(got, []string{"high", "medium"}) {
+		t.Errorf("Expected the oldest job to be dropped, got %v", got)
+	}
+}
+
+func TestCapacityDropLowestPriority(t *testing.T) {
+	q := NewQueue(WithCapacity(utils.DefaultQueue, 2, DropLowestPriority))
+	now := time.Now()
+
+	q.AddJob(utils.Job{ID: "high-old", Priority: utils.High, CreatedAt: now.Add(-time.Minute)})
+	q.AddJob(utils.Job{ID: "medium", Priority: utils.Medium, CreatedAt: now})
+	if err := q.AddJob(utils.Job{ID: "low", Priority: utils.Low, CreatedAt: now}); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("Expected a job less urgent than every queued job to be rejected, got %v", err)
+	}
+	if err := q.AddJob(utils.Job{ID: "high-new", Priority: utils.High, CreatedAt: now}); err != nil {
+		t.Fatalf("AddJob failed: %v", err)
+	}
+
+	if got := ids(t, q); !equal(got, []string{"high-old", "high-new"}) {
+		t.Errorf("Expected the Medium job to be dropped, got %v", got)
+	}
+}
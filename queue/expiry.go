@@ -0,0 +1,81 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Avik-creator/utils"
+)
+
+// ExpiredReason is the DeadReason of jobs dead-lettered because they expired.
+const ExpiredReason = "expired"
+
+// ErrJobExpired is returned by AddJob and AddJobs for a job whose ExpiresAt
+// has passed.
+var ErrJobExpired = errors.New("job expired")
+
+func errExpired(job utils.Job) error {
+	return fmt.Errorf("%w: job %s expired at %s", ErrJobExpired, job.ID, job.ExpiresAt.Format(time.RFC3339))
+}
+
+// ExpiryPolicy says what happens to a job found waiting after its ExpiresAt.
+type ExpiryPolicy int
+
+const (
+	// DiscardExpired drops the job.
+	DiscardExpired ExpiryPolicy = iota
+	// DeadLetterExpired moves the job to the dead-letter queue with
+	// ExpiredReason as its DeadReason.
+	DeadLetterExpired
+)
+
+func (e ExpiryPolicy) String() string {
+	switch e {
+	case DiscardExpired:
+		return "discard"
+	case DeadLetterExpired:
+		return "dead-letter"
+	}
+	return fmt.Sprintf("ExpiryPolicy(%d)", int(e))
+}
+
+// WithExpiry sets what happens to expired jobs. The default is
+// DiscardExpired.
+func WithExpiry(policy ExpiryPolicy) Option {
+	return func(q *JobQueue) {
+		q.expiry = policy
+	}
+}
+
+// expire takes job, which has expired, out of its bucket, out of flight or
+// off the schedule and discards or dead-letters it. It is called with q.mu
+// held.
+func (q *JobQueue) expire(job utils.Job) error {
+	delete(q.leases, job.ID)
+	defer q.freed()
+
+	if q.expiry == DeadLetterExpired {
+		job.DeadReason = ExpiredReason
+		if err := q.record(opDeadLetter, job); err != nil {
+			return err
+		}
+		if err := q.storage.MoveToDeadLetter(job); err != nil {
+			return err
+		}
+		q.jobs.set(job, utils.Dead)
+		return nil
+	}
+
+	if err := q.record(opRemove, job); err != nil {
+		return err
+	}
+	if err := q.storage.Remove(job); err != nil {
+		return err
+	}
+	if err := q.storage.Ack(job); err != nil {
+		return err
+	}
+	q.jobs.forget(job.ID)
+	return nil
+}
@@ -0,0 +1,111 @@
+package queue
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Avik-creator/utils"
+)
+
+func expiringJob(id string, ttl time.Duration) utils.Job {
+	now := time.Now()
+	return utils.Job{ID: id, Priority: utils.High, CreatedAt: now, ExpiresAt: now.Add(ttl)}
+}
+
+func TestExpiredJobIsDiscarded(t *testing.T) {
+	q := NewQueue()
+	q.AddJob(expiringJob("stale", 10*time.Millisecond))
+	q.AddJob(expiringJob("fresh", time.Hour))
+	time.Sleep(20 * time.Millisecond)
+
+	job, err := q.GetJob()
+	if err != nil || job.ID != "fresh" {
+		t.Fatalf("Expected the expired job to be passed over, got %s (%v)", job.ID, err)
+	}
+	if _, err := q.GetJob(); !errors.Is(err, ErrEmpty) {
+		t.Errorf("Expected the queue to be empty, got %v", err)
+	}
+	if _, err := q.GetJobByID("stale"); !errors.Is(err, ErrUnknownJob) {
+		t.Errorf("Expected the discarded job to be forgotten, got %v", err)
+	}
+	if high, _, _, _ := q.GetAllDeadLetterJobs(); len(high) != 0 {
+		t.Errorf("Expected no dead-letter jobs, got %v", high)
+	}
+}
+
+func TestExpiredJobIsDeadLettered(t *testing.T) {
+	q := NewQueue(WithExpiry(DeadLetterExpired))
+	q.AddJob(expiringJob("stale", 10*time.Millisecond))
+	time.Sleep(20 * time.Millisecond)
+
+	if _, err := q.Lease(time.Minute); !errors.Is(err, ErrEmpty) {
+		t.Fatalf("Expected no job to lease, got %v", err)
+	}
+	high, _, _, _ := q.GetAllDeadLetterJobs()
+	if len(high) != 1 || high[0].ID != "stale" || high[0].DeadReason != ExpiredReason {
+		t.Fatalf("Expected the expired job in the dead-letter queue, got %v", high)
+	}
+	if info, _ := q.GetJobByID("stale"); info.State != utils.Dead {
+		t.Errorf("Expected the expired job to be dead, got %s", info.State)
+	}
+}
+
+func TestAddExpiredJob(t *testing.T) {
+	q := NewQueue(WithExpiry(DeadLetterExpired))
+	job := expiringJob("job1", 10*time.Millisecond)
+	q.Schedule(job, time.Now())
+	time.Sleep(20 * time.Millisecond)
+
+	if err := q.AddJob(job); !errors.Is(err, ErrJobExpired) {
+		t.Fatalf("Expected ErrJobExpired, got %v", err)
+	}
+	if scheduled := q.ScheduledJobs(); len(scheduled) != 0 {
+		t.Errorf("Expected the expired job to leave the schedule, got %v", scheduled)
+	}
+	if high, _, _, _ := q.GetAllDeadLetterJobs(); len(high) != 1 {
+		t.Errorf("Expected the expired job in the dead-letter queue, got %v", high)
+	}
+}
+
+func TestScheduledJobExpiresBeforeItIsDue(t *testing.T) {
+	q := NewQueue(WithExpiry(DeadLetterExpired))
+	q.Schedule(expiringJob("stale", 10*time.Millisecond), time.Now().Add(time.Hour))
+	q.Schedule(expiringJob("fresh", time.Hour), time.Now().Add(time.Hour))
+	time.Sleep(20 * time.Millisecond)
+
+	if scheduled := q.ScheduledJobs(); len(scheduled) != 1 || scheduled[0].Job.ID != "fresh" {
+		t.Errorf("Expected the expired job to be left out of the schedule, got %v", scheduled)
+	}
+	due, err := q.TakeDueJobs(time.Now())
+	if err != nil || len(due) != 1 || due[0].Job.ID != "stale" {
+		t.Fatalf("Expected the expired job to be taken before it is due, got %v (%v)", due, err)
+	}
+	if err := q.AddJob(due[0].Job); !errors.Is(err, ErrJobExpired) {
+		t.Fatalf("Expected ErrJobExpired, got %v", err)
+	}
+	if high, _, _, _ := q.GetAllDeadLetterJobs(); len(high) != 1 || high[0].ID != "stale" {
+		t.Errorf("Expected the expired job in the dead-letter queue, got %v", high)
+	}
+}
+
+func TestExpiredJobStaysDeadAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+
+	q := NewQueue(WithLog(openTestLog(t, dir)), WithExpiry(DeadLetterExpired))
+	q.AddJob(expiringJob("stale", 10*time.Millisecond))
+	time.Sleep(20 * time.Millisecond)
+	q.GetJob()
+	q.Close()
+
+	q = NewQueue(WithLog(openTestLog(t, dir)))
+	defer q.Close()
+
+	high, _, _, _ := q.GetAllDeadLetterJobs()
+	if len(high) != 1 || high[0].DeadReason != ExpiredReason {
+		t.Errorf("Expected the expired job in the dead-letter queue after a restart, got %v", high)
+	}
+	if all, _, _, _ := q.GetAllJobs(); len(all) != 0 {
+		t.Errorf("Expected no queued jobs after a restart, got %v", all)
+	}
+}
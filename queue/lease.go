@@ -0,0 +1,161 @@
+package queue
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/Avik-creator/utils"
+)
+
+const DefaultLeaseTimeout = 30 * time.Second
+
+var ErrLeaseExpired = errors.New("lease expired or already released")
+
+// Lease is a job handed to a worker until Deadline. The worker must Ack it
+// on success or Nack it on failure; if it does neither before the deadline
+// the job goes back to its priority bucket for another worker to pick up.
+type Lease struct {
+	Job      utils.Job
+	Deadline time.Time
+	token    uint64
+}
+
+// Lease takes the next job by priority from the named queues, or from the
+// default queue when none are given, and leases it for timeout.
+func (q *JobQueue) Lease(timeout time.Duration, queues ...string) (*Lease, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.lease(timeout, queues)
+}
+
+func (q *JobQueue) lease(timeout time.Duration, queues []string) (*Lease, error) {
+	q.requeueExpired()
+	deadline := time.Now().Add(timeout)
+	job, ok, err := q.dequeue(queues, deadline)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrEmpty
+	}
+	if err := q.recordAt(opLease, job, deadline); err != nil {
+		if err := q.storage.Enqueue(job); err != nil {
+			log.Printf("queue: failed to requeue job %s: %v", job.ID, err)
+		}
+		return nil, err
+	}
+	q.nextLease++
+	q.leases[job.ID] = q.nextLease
+	q.jobs.set(job, utils.Running)
+	q.freed()
+	return &Lease{Job: job, Deadline: deadline, token: q.nextLease}, nil
+}
+
+// Ack marks the leased job as done and forgets it.
+func (q *JobQueue) Ack(l *Lease) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if err := q.checkLease(l); err != nil {
+		return err
+	}
+	return q.ack(l)
+}
+
+func (q *JobQueue) ack(l *Lease) error {
+	if err := q.record(opAck, l.Job); err != nil {
+		return err
+	}
+	delete(q.leases, l.Job.ID)
+	if err := q.storage.Ack(l.Job); err != nil {
+		return err
+	}
+	q.jobs.set(l.Job, utils.Succeeded)
+	return nil
+}
+
+// Nack releases the lease and puts l.Job back in its priority bucket once
+// delay has passed. Callers may update l.Job first, e.g. to bump its
+// RetryCount.
+func (q *JobQueue) Nack(l *Lease, delay time.Duration) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if err := q.checkLease(l); err != nil {
+		return err
+	}
+	delete(q.leases, l.Job.ID)
+	if err := q.hold(l.Job, time.Now().Add(delay)); err != nil {
+		return err
+	}
+	q.jobs.set(l.Job, utils.Retrying)
+	return nil
+}
+
+// Extend pushes the lease's deadline to timeout from now.
+func (q *JobQueue) Extend(l *Lease, timeout time.Duration) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if err := q.checkLease(l); err != nil {
+		return err
+	}
+	deadline := time.Now().Add(timeout)
+	if err := q.hold(l.Job, deadline); err != nil {
+		return err
+	}
+	l.Deadline = deadline
+	return nil
+}
+
+func (q *JobQueue) checkLease(l *Lease) error {
+	q.requeueExpired()
+	if token, ok := q.leases[l.Job.ID]; !ok || token != l.token {
+		return ErrLeaseExpired
+	}
+	return nil
+}
+
+func (q *JobQueue) hold(job utils.Job, until time.Time) error {
+	if err := checkPriority(job); err != nil {
+		return err
+	}
+	if err := q.recordAt(opHold, job, until); err != nil {
+		return err
+	}
+	if err := q.storage.Hold(job, until); err != nil {
+		return err
+	}
+	// Blocked dequeues need to re-arm their timers for the new deadline.
+	q.notify()
+	return nil
+}
+
+// requeueExpired returns every in-flight job whose lease has run out to its
+// priority bucket. It is called with q.mu held.
+func (q *JobQueue) requeueExpired() {
+	held, err := q.storage.ListExpired(time.Now())
+	if err != nil {
+		log.Printf("queue: failed to list expired leases: %v", err)
+		return
+	}
+
+	requeued := false
+	for _, h := range held {
+		if err := q.record(opAdd, h.Job); err != nil {
+			log.Printf("queue: failed to log requeue of job %s: %v", h.Job.ID, err)
+		}
+		if err := q.storage.Enqueue(h.Job); err != nil {
+			log.Printf("queue: failed to requeue job %s: %v", h.Job.ID, err)
+			continue
+		}
+		delete(q.leases, h.Job.ID)
+		q.jobs.set(h.Job, utils.Queued)
+		requeued = true
+	}
+	if requeued {
+		q.notify()
+	}
+}
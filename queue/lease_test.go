@@ -0,0 +1,192 @@
+package queue
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Avik-creator/utils"
+)
+
+func TestLeaseAck(t *testing.T) {
+	q := NewQueue()
+	m := q.storage.(*MemoryStorage)
+
+	q.AddJob(utils.Job{ID: "job1", Priority: utils.High, CreatedAt: time.Now()})
+
+	l, err := q.Lease(time.Minute)
+	if err != nil {
+		t.Fatalf("Lease failed: %v", err)
+	}
+	if l.Job.ID != "job1" {
+		t.Errorf("Expected job1, got %s", l.Job.ID)
+	}
+	if len(m.inFlight) != 1 {
+		t.Errorf("Expected 1 in-flight job, got %d", len(m.inFlight))
+	}
+	if _, err := q.Lease(time.Minute); err == nil {
+		t.Error("Expected leased job to be hidden from other workers")
+	}
+
+	if err := q.Ack(l); err != nil {
+		t.Fatalf("Ack failed: %v", err)
+	}
+	if len(m.inFlight) != 0 {
+		t.Errorf("Expected no in-flight jobs after Ack, got %d", len(m.inFlight))
+	}
+	if err := q.Ack(l); !errors.Is(err, ErrLeaseExpired) {
+		t.Errorf("Expected ErrLeaseExpired when acking twice, got %v", err)
+	}
+}
+
+func TestLeaseNack(t *testing.T) {
+	q := NewQueue()
+
+	q.AddJob(utils.Job{ID: "job1", Priority: utils.High, CreatedAt: time.Now()})
+	l, err := q.Lease(time.Minute)
+	if err != nil {
+		t.Fatalf("Lease failed: %v", err)
+	}
+
+	l.Job.RetryCount++
+	if err := q.Nack(l, 30*time.Millisecond); err != nil {
+		t.Fatalf("Nack failed: %v", err)
+	}
+	if _, err := q.Lease(time.Minute); err == nil {
+		t.Error("Expected nacked job to stay hidden until its delay has passed")
+	}
+	if err := q.Ack(l); !errors.Is(err, ErrLeaseExpired) {
+		t.Errorf("Expected ErrLeaseExpired when acking a nacked lease, got %v", err)
+	}
+
+	time.Sleep(40 * time.Millisecond)
+	l, err = q.Lease(time.Minute)
+	if err != nil {
+		t.Fatalf("Expected nacked job to be back after its delay, got error: %v", err)
+	}
+	if l.Job.RetryCount != 1 {
+		t.Errorf("Expected RetryCount 1, got %d", l.Job.RetryCount)
+	}
+}
+
+func TestLeaseExpires(t *testing.T) {
+	q := NewQueue()
+
+	q.AddJob(utils.Job{ID: "job1", Priority: utils.Medium, CreatedAt: time.Now()})
+	stale, err := q.Lease(20 * time.Millisecond)
+	if err != nil {
+		t.Fatalf("Lease failed: %v", err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+
+	_, mediumJobs, _, _ := q.GetAllJobs()
+	if len(mediumJobs) != 1 {
+		t.Fatalf("Expected expired job to be back in its bucket, got %d jobs", len(mediumJobs))
+	}
+
+	fresh, err := q.Lease(time.Minute)
+	if err != nil {
+		t.Fatalf("Lease failed: %v", err)
+	}
+	if err := q.Ack(stale); !errors.Is(err, ErrLeaseExpired) {
+		t.Errorf("Expected ErrLeaseExpired for the expired lease, got %v", err)
+	}
+	if err := q.Ack(fresh); err != nil {
+		t.Errorf("Expected the new lease to ack cleanly, got %v", err)
+	}
+}
+
+func TestLeaseExtend(t *testing.T) {
+	q := NewQueue()
+
+	q.AddJob(utils.Job{ID: "job1", Priority: utils.Low, CreatedAt: time.Now()})
+	l, err := q.Lease(20 * time.Millisecond)
+	if err != nil {
+		t.Fatalf("Lease failed: %v", err)
+	}
+	if err := q.Extend(l, time.Minute); err != nil {
+		t.Fatalf("Extend failed: %v", err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if err := q.Ack(l); err != nil {
+		t.Errorf("Expected extended lease to still be valid, got %v", err)
+	}
+}
+
+func TestMemoryStorageListExpired(t *testing.T) {
+	s := NewMemoryStorage()
+
+	now := time.Now()
+	s.Hold(utils.Job{ID: "job1", Queue: utils.DefaultQueue, Priority: utils.High}, now.Add(-time.Second))
+	s.Hold(utils.Job{ID: "job2", Queue: utils.DefaultQueue, Priority: utils.High}, now.Add(time.Hour))
+	s.Hold(utils.Job{ID: "job3", Queue: utils.DefaultQueue, Priority: utils.Low}, now.Add(-2*time.Second))
+	// Extending job3 moves its deadline past now.
+	s.Hold(utils.Job{ID: "job3", Queue: utils.DefaultQueue, Priority: utils.Low}, now.Add(2*time.Hour))
+
+	held, err := s.ListExpired(now)
+	if err != nil || len(held) != 1 || held[0].Job.ID != "job1" {
+		t.Fatalf("Expected only job1 to have expired, got %v (%v)", held, err)
+	}
+	if until, ok, err := s.NextDeadline(); err != nil || !ok || !until.Equal(held[0].Until) {
+		t.Errorf("Expected the next deadline to be job1's, got %v %v (%v)", until, ok, err)
+	}
+
+	s.Ack(utils.Job{ID: "job1"})
+	if held, _ := s.ListExpired(now); len(held) != 0 {
+		t.Errorf("Expected no expired leases after Ack, got %v", held)
+	}
+	if until, ok, _ := s.NextDeadline(); !ok || !until.Equal(now.Add(time.Hour)) {
+		t.Errorf("Expected job2's deadline next, got %v %v", until, ok)
+	}
+
+	s.Enqueue(utils.Job{ID: "job2", Queue: utils.DefaultQueue, Priority: utils.High})
+	s.MoveToDeadLetter(utils.Job{ID: "job3", Queue: utils.DefaultQueue, Priority: utils.Low})
+	if _, ok, _ := s.NextDeadline(); ok {
+		t.Errorf("Expected no deadline with nothing in flight")
+	}
+}
+
+func TestLeaseSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+
+	q := NewQueue(WithLog(openTestLog(t, dir)))
+	q.AddJob(utils.Job{ID: "job1", Priority: utils.High})
+	q.AddJob(utils.Job{ID: "job2", Priority: utils.High})
+	if _, err := q.Lease(30 * time.Millisecond); err != nil {
+		t.Fatalf("Lease failed: %v", err)
+	}
+	l, err := q.Lease(time.Minute)
+	if err != nil {
+		t.Fatalf("Lease failed: %v", err)
+	}
+	q.Ack(l)
+	// Crash without acking job1.
+	q.Close()
+
+	q = NewQueue(WithLog(openTestLog(t, dir)))
+	if err := q.Snapshot(); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	q.Close()
+
+	q = NewQueue(WithLog(openTestLog(t, dir)))
+	defer q.Close()
+
+	if _, err := q.Lease(time.Minute); err == nil {
+		t.Fatal("Expected job1 to stay leased until its deadline")
+	}
+	time.Sleep(40 * time.Millisecond)
+
+	l, err = q.Lease(time.Minute)
+	if err != nil {
+		t.Fatalf("Expected job1 to return after its lease expired, got error: %v", err)
+	}
+	if l.Job.ID != "job1" {
+		t.Errorf("Expected job1, got %s", l.Job.ID)
+	}
+	if _, err := q.Lease(time.Minute); err == nil {
+		t.Error("Expected acked job2 not to come back")
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package queue
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// lockDir only creates the lock file: there is no flock here, so nothing
+// stops a second process from opening the same log.
+func lockDir(dir string) (*os.File, error) {
+	return os.OpenFile(filepath.Join(dir, lockName), os.O_RDWR|os.O_CREATE, 0o644)
+}
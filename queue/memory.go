@@ -0,0 +1,546 @@
+package queue
+
+import (
+	"cmp"
+	"container/heap"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/Avik-creator/utils"
+)
+
+// MemoryStorage is the default Storage. Its contents are lost when the
+// process exits.
+type MemoryStorage struct {
+	queue           map[string]*memoryQueue
+	deadLetterQueue map[string]*memoryQueue
+	inFlight        map[string]HeldJob
+	deadlines       deadlineHeap
+	results         map[string]Result
+	pauses          map[Pause]bool
+	scheduled       map[string]ScheduledJob
+	claims          map[string]Claim
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		queue:           map[string]*memoryQueue{utils.DefaultQueue: newMemoryQueue()},
+		deadLetterQueue: map[string]*memoryQueue{utils.DefaultQueue: newMemoryQueue()},
+		inFlight:        make(map[string]HeldJob),
+		results:         make(map[string]Result),
+		pauses:          make(map[Pause]bool),
+		scheduled:       make(map[string]ScheduledJob),
+		claims:          make(map[string]Claim),
+	}
+}
+
+// memoryQueue holds the jobs of one named queue: a ring buffer per priority,
+// sorted by CreatedAt, an index from job ID to priority and a heap of the
+// priorities whose buckets are not empty.
+type memoryQueue struct {
+	levels  priorityHeap
+	buckets map[utils.Priority]*ring
+	index   map[string]utils.Priority
+}
+
+func newMemoryQueue() *memoryQueue {
+	return &memoryQueue{buckets: make(map[utils.Priority]*ring), index: make(map[string]utils.Priority)}
+}
+
+// get returns the named queue in m, creating it on first use.
+func get(m map[string]*memoryQueue, queue string) *memoryQueue {
+	mq, ok := m[queue]
+	if !ok {
+		mq = newMemoryQueue()
+		m[queue] = mq
+	}
+	return mq
+}
+
+// push adds job after every job in its bucket created no later than it,
+// replacing any job already queued under the same ID.
+func (mq *memoryQueue) push(job utils.Job) {
+	mq.remove(job)
+	b, ok := mq.buckets[job.Priority]
+	if !ok {
+		b = newRing()
+		mq.buckets[job.Priority] = b
+		heap.Push(&mq.levels, job.Priority)
+	}
+	b.push(job)
+	mq.index[job.ID] = job.Priority
+}
+
+func (mq *memoryQueue) pop(priority utils.Priority) (utils.Job, bool) {
+	b, ok := mq.buckets[priority]
+	if !ok {
+		return utils.Job{}, false
+	}
+	job, ok := b.pop()
+	if ok {
+		delete(mq.index, job.ID)
+	}
+	if b.len() == 0 {
+		mq.drop(priority)
+	}
+	return job, ok
+}
+
+// popSkipping takes the first job of the bucket whose Type is not in skip.
+func (mq *memoryQueue) popSkipping(priority utils.Priority, skip []string) (utils.Job, bool) {
+	if len(skip) == 0 {
+		return mq.pop(priority)
+	}
+	b, ok := mq.buckets[priority]
+	if !ok {
+		return utils.Job{}, false
+	}
+	job, ok := b.first(func(j utils.Job) bool { return !slices.Contains(skip, j.Type) })
+	if ok {
+		mq.remove(job)
+	}
+	return job, ok
+}
+
+// remove takes the job with job's ID out of whichever bucket holds it.
+func (mq *memoryQueue) remove(job utils.Job) {
+	p, ok := mq.index[job.ID]
+	if !ok {
+		return
+	}
+	delete(mq.index, job.ID)
+	b := mq.buckets[p]
+	b.remove(job.ID)
+	if b.len() == 0 {
+		mq.drop(p)
+	}
+}
+
+// drop forgets an emptied bucket.
+func (mq *memoryQueue) drop(priority utils.Priority) {
+	delete(mq.buckets, priority)
+	for i, p := range mq.levels {
+		if p == priority {
+			heap.Remove(&mq.levels, i)
+			return
+		}
+	}
+}
+
+func (mq *memoryQueue) priorities() []utils.Priority {
+	ps := append([]utils.Priority{}, mq.levels...)
+	sort.Slice(ps, func(i, j int) bool { return ps[i] < ps[j] })
+	return ps
+}
+
+type priorityHeap []utils.Priority
+
+func (h priorityHeap) Len() int           { return len(h) }
+func (h priorityHeap) Less(i, j int) bool { return h[i] < h[j] }
+func (h priorityHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *priorityHeap) Push(x any) { *h = append(*h, x.(utils.Priority)) }
+
+func (h *priorityHeap) Pop() any {
+	old := *h
+	p := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return p
+}
+
+// deadline is when the lease on the in-flight job with ID id runs out.
+type deadline struct {
+	until time.Time
+	id    string
+}
+
+type deadlineHeap []deadline
+
+func (h deadlineHeap) Len() int           { return len(h) }
+func (h deadlineHeap) Less(i, j int) bool { return h[i].until.Before(h[j].until) }
+func (h deadlineHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *deadlineHeap) Push(x any) { *h = append(*h, x.(deadline)) }
+
+func (h *deadlineHeap) Pop() any {
+	old := *h
+	d := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return d
+}
+
+func checkPriority(job utils.Job) error {
+	if !job.Priority.Valid() {
+		return fmt.Errorf("%w %d for job %s", ErrInvalidPriority, job.Priority, job.ID)
+	}
+	return nil
+}
+
+func (m *MemoryStorage) Enqueue(job utils.Job) error {
+	if err := checkPriority(job); err != nil {
+		return err
+	}
+	delete(m.inFlight, job.ID)
+	delete(m.scheduled, job.ID)
+	get(m.queue, job.Queue).push(job)
+	return nil
+}
+
+func (m *MemoryStorage) EnqueueBatch(jobs []utils.Job, claims []Claim) error {
+	for _, job := range jobs {
+		if err := checkPriority(job); err != nil {
+			return err
+		}
+	}
+	if err := m.claim(claims); err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		m.Enqueue(job)
+	}
+	return nil
+}
+
+func (m *MemoryStorage) Dequeue(queue string, priority utils.Priority, leaseUntil time.Time) (utils.Job, bool, error) {
+	return m.DequeueSkipping(queue, priority, nil, leaseUntil)
+}
+
+func (m *MemoryStorage) DequeueSkipping(queue string, priority utils.Priority, skipTypes []string, leaseUntil time.Time) (utils.Job, bool, error) {
+	mq, ok := m.queue[queue]
+	if !ok {
+		return utils.Job{}, false, nil
+	}
+	job, ok := mq.popSkipping(priority, skipTypes)
+	if ok && !leaseUntil.IsZero() {
+		m.hold(job, leaseUntil)
+	}
+	return job, ok, nil
+}
+
+func (m *MemoryStorage) Job(id string) (utils.Job, bool, error) {
+	if h, ok := m.inFlight[id]; ok {
+		return h.Job, true, nil
+	}
+	for _, qs := range []map[string]*memoryQueue{m.queue, m.deadLetterQueue} {
+		for _, mq := range qs {
+			if p, ok := mq.index[id]; ok {
+				job, _ := mq.buckets[p].job(id)
+				return job, true, nil
+			}
+		}
+	}
+	return utils.Job{}, false, nil
+}
+
+func (m *MemoryStorage) Peek(queue string, priority utils.Priority) (utils.Job, bool, error) {
+	if mq, ok := m.queue[queue]; ok {
+		if b, ok := mq.buckets[priority]; ok {
+			job, ok := b.peek()
+			return job, ok, nil
+		}
+	}
+	return utils.Job{}, false, nil
+}
+
+func (m *MemoryStorage) Len(queue string, priority utils.Priority) (int, error) {
+	if mq, ok := m.queue[queue]; ok {
+		return mq.buckets[priority].len(), nil
+	}
+	return 0, nil
+}
+
+func (m *MemoryStorage) Hold(job utils.Job, until time.Time) error {
+	if err := checkPriority(job); err != nil {
+		return err
+	}
+	m.hold(job, until)
+	return nil
+}
+
+// hold puts job in flight until until. The deadline it replaces, if any, is
+// left in m.deadlines and skipped once it reaches the top.
+func (m *MemoryStorage) hold(job utils.Job, until time.Time) {
+	m.inFlight[job.ID] = HeldJob{Job: job, Until: until}
+	heap.Push(&m.deadlines, deadline{until: until, id: job.ID})
+}
+
+func (m *MemoryStorage) Ack(job utils.Job) error {
+	delete(m.inFlight, job.ID)
+	return nil
+}
+
+func (m *MemoryStorage) ListInFlight() ([]HeldJob, error) {
+	held := make([]HeldJob, 0, len(m.inFlight))
+	for _, h := range m.inFlight {
+		held = append(held, h)
+	}
+	sort.Slice(held, func(i, j int) bool { return held[i].Until.Before(held[j].Until) })
+	return held, nil
+}
+
+func (m *MemoryStorage) ListExpired(now time.Time) ([]HeldJob, error) {
+	var held []HeldJob
+	var expired []deadline
+	seen := make(map[string]bool)
+	for m.trimDeadlines() && !m.deadlines[0].until.After(now) {
+		d := heap.Pop(&m.deadlines).(deadline)
+		if seen[d.id] {
+			continue
+		}
+		seen[d.id] = true
+		expired = append(expired, d)
+		held = append(held, m.inFlight[d.id])
+	}
+	// The jobs stay in flight until the caller requeues them.
+	for _, d := range expired {
+		heap.Push(&m.deadlines, d)
+	}
+	return held, nil
+}
+
+func (m *MemoryStorage) NextDeadline() (time.Time, bool, error) {
+	if !m.trimDeadlines() {
+		return time.Time{}, false, nil
+	}
+	return m.deadlines[0].until, true, nil
+}
+
+// trimDeadlines pops the deadlines of jobs no longer in flight, or since held
+// until another time, off the top of m.deadlines and reports whether any are
+// left.
+func (m *MemoryStorage) trimDeadlines() bool {
+	for len(m.deadlines) > 0 {
+		d := m.deadlines[0]
+		if h, ok := m.inFlight[d.id]; ok && h.Until.Equal(d.until) {
+			return true
+		}
+		heap.Pop(&m.deadlines)
+	}
+	return false
+}
+
+func (m *MemoryStorage) Remove(job utils.Job) error {
+	if mq, ok := m.queue[job.Queue]; ok {
+		mq.remove(job)
+	}
+	delete(m.scheduled, job.ID)
+	return nil
+}
+
+func (m *MemoryStorage) MoveToDeadLetter(job utils.Job) error {
+	if err := checkPriority(job); err != nil {
+		return err
+	}
+	delete(m.inFlight, job.ID)
+	delete(m.scheduled, job.ID)
+	if mq, ok := m.queue[job.Queue]; ok {
+		mq.remove(job)
+	}
+	get(m.deadLetterQueue, job.Queue).push(job)
+	return nil
+}
+
+func (m *MemoryStorage) DequeueDeadLetter(queue string, priority utils.Priority) (utils.Job, bool, error) {
+	mq, ok := m.deadLetterQueue[queue]
+	if !ok {
+		return utils.Job{}, false, nil
+	}
+	job, ok := mq.pop(priority)
+	return job, ok, nil
+}
+
+func (m *MemoryStorage) List(queue string, priority utils.Priority) ([]utils.Job, error) {
+	return listJobs(m.queue, queue, priority), nil
+}
+
+func (m *MemoryStorage) ListDeadLetter(queue string, priority utils.Priority) ([]utils.Job, error) {
+	return listJobs(m.deadLetterQueue, queue, priority), nil
+}
+
+func listJobs(m map[string]*memoryQueue, queue string, priority utils.Priority) []utils.Job {
+	mq, ok := m[queue]
+	if !ok {
+		return nil
+	}
+	return mq.buckets[priority].jobs()
+}
+
+func (m *MemoryStorage) Priorities(queue string) ([]utils.Priority, error) {
+	if mq, ok := m.queue[queue]; ok {
+		return mq.priorities(), nil
+	}
+	return nil, nil
+}
+
+func (m *MemoryStorage) DeadLetterPriorities(queue string) ([]utils.Priority, error) {
+	if mq, ok := m.deadLetterQueue[queue]; ok {
+		return mq.priorities(), nil
+	}
+	return nil, nil
+}
+
+func (m *MemoryStorage) Queues() ([]string, error) {
+	seen := make(map[string]bool)
+	for _, qs := range []map[string]*memoryQueue{m.queue, m.deadLetterQueue} {
+		for name, mq := range qs {
+			if len(mq.levels) > 0 {
+				seen[name] = true
+			}
+		}
+	}
+	for _, h := range m.inFlight {
+		seen[h.Job.Queue] = true
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (m *MemoryStorage) SaveResult(r Result) error {
+	m.results[r.JobID] = r
+	return nil
+}
+
+func (m *MemoryStorage) Result(jobID string) (Result, bool, error) {
+	r, ok := m.results[jobID]
+	return r, ok, nil
+}
+
+func (m *MemoryStorage) Results() ([]Result, error) {
+	results := make([]Result, 0, len(m.results))
+	for _, r := range m.results {
+		results = append(results, r)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].CreatedAt.Before(results[j].CreatedAt) })
+	return results, nil
+}
+
+func (m *MemoryStorage) PruneResults(now time.Time) error {
+	for id, r := range m.results {
+		if !r.ExpiresAt.After(now) {
+			delete(m.results, id)
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStorage) AddPause(p Pause) error {
+	m.pauses[p] = true
+	return nil
+}
+
+func (m *MemoryStorage) RemovePause(p Pause) error {
+	delete(m.pauses, p)
+	return nil
+}
+
+func (m *MemoryStorage) Pauses() ([]Pause, error) {
+	pauses := make([]Pause, 0, len(m.pauses))
+	for p := range m.pauses {
+		pauses = append(pauses, p)
+	}
+	slices.SortFunc(pauses, func(a, b Pause) int {
+		return cmp.Or(cmp.Compare(a.Queue, b.Queue), cmp.Compare(a.Priority, b.Priority), cmp.Compare(a.Type, b.Type))
+	})
+	return pauses, nil
+}
+
+func (m *MemoryStorage) Schedule(sj ScheduledJob, claims []Claim) error {
+	if err := m.claim(claims); err != nil {
+		return err
+	}
+	m.scheduled[sj.Job.ID] = sj
+	return nil
+}
+
+func (m *MemoryStorage) ScheduledJob(id string) (ScheduledJob, bool, error) {
+	sj, ok := m.scheduled[id]
+	return sj, ok, nil
+}
+
+func (m *MemoryStorage) ScheduledJobs() ([]ScheduledJob, error) {
+	return m.schedules(func(ScheduledJob) bool { return true }), nil
+}
+
+func (m *MemoryStorage) TakeDue(now time.Time) ([]ScheduledJob, error) {
+	due := m.schedules(func(sj ScheduledJob) bool { return !sj.ScheduleTime.After(now) || sj.Job.Expired(now) })
+	for _, sj := range due {
+		delete(m.scheduled, sj.Job.ID)
+	}
+	return due, nil
+}
+
+// schedules returns the schedules that match, soonest first.
+func (m *MemoryStorage) schedules(match func(ScheduledJob) bool) []ScheduledJob {
+	jobs := make([]ScheduledJob, 0)
+	for _, sj := range m.scheduled {
+		if match(sj) {
+			jobs = append(jobs, sj)
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].ScheduleTime.Before(jobs[j].ScheduleTime) })
+	return jobs
+}
+
+// claim takes claims, or none of them when one of their keys is held by
+// another job, including one claimed earlier in claims.
+func (m *MemoryStorage) claim(claims []Claim) error {
+	for i, c := range claims {
+		id, ok := m.holder(c)
+		for _, earlier := range claims[:i] {
+			if earlier.Key == c.Key && earlier.JobID != c.JobID {
+				id, ok = earlier.JobID, true
+			}
+		}
+		if ok {
+			return fmt.Errorf("%w: job %s has the unique key %q of job %s", ErrDuplicateJob, c.JobID, c.Key, id)
+		}
+	}
+	for _, c := range claims {
+		if held, ok := m.claims[c.Key]; !ok || held.JobID != c.JobID {
+			m.claims[c.Key] = c
+		}
+	}
+	return nil
+}
+
+// holder returns the ID of another job holding the key of c, if any.
+func (m *MemoryStorage) holder(c Claim) (string, bool) {
+	held, ok := m.claims[c.Key]
+	if !ok || held.JobID == c.JobID || !held.Held(c.At, m.live(held.JobID)) {
+		return "", false
+	}
+	return held.JobID, true
+}
+
+// live reports whether the job with the given ID is scheduled, queued or in
+// flight.
+func (m *MemoryStorage) live(id string) bool {
+	if _, ok := m.scheduled[id]; ok {
+		return true
+	}
+	if _, ok := m.inFlight[id]; ok {
+		return true
+	}
+	for _, mq := range m.queue {
+		if _, ok := mq.index[id]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MemoryStorage) PruneClaims(now time.Time) error {
+	for key, c := range m.claims {
+		if !c.Held(now, m.live(c.JobID)) {
+			delete(m.claims, key)
+		}
+	}
+	return nil
+}
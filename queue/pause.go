@@ -0,0 +1,127 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Avik-creator/utils"
+)
+
+// ErrEmptyPause is returned by Pause and Resume for a Pause that names
+// nothing, which would match every job.
+var ErrEmptyPause = errors.New("pause names no queue, priority or type")
+
+// Pause stops dequeues of the jobs it matches, while AddJob keeps accepting
+// them. Empty fields match anything, so Pause{Queue: "emails"} pauses a whole
+// queue, Pause{Queue: "emails", Priority: utils.Low} one of its buckets and
+// Pause{Type: "email"} the email jobs of every queue.
+type Pause struct {
+	Queue    string         `json:"queue,omitempty"`
+	Priority utils.Priority `json:"priority,omitempty"`
+	Type     string         `json:"type,omitempty"`
+}
+
+func (p Pause) String() string {
+	var parts []string
+	if p.Queue != "" {
+		parts = append(parts, "queue "+p.Queue)
+	}
+	if p.Priority != 0 {
+		parts = append(parts, fmt.Sprintf("priority %d", p.Priority))
+	}
+	if p.Type != "" {
+		parts = append(parts, "type "+p.Type)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (p Pause) check() error {
+	if p == (Pause{}) {
+		return ErrEmptyPause
+	}
+	if p.Priority != 0 && !p.Priority.Valid() {
+		return fmt.Errorf("%w %d", ErrInvalidPriority, p.Priority)
+	}
+	return nil
+}
+
+// applies reports whether p matches the bucket of the given queue and
+// priority, ignoring its Type.
+func (p Pause) applies(queue string, priority utils.Priority) bool {
+	return (p.Queue == "" || p.Queue == queue) && (p.Priority == 0 || p.Priority == priority)
+}
+
+// Pause stops dequeues of the jobs p matches until Resume is called with the
+// same p. Pauses are kept by the storage, so they survive a restart and, with
+// a shared database, apply to every process using it.
+func (q *JobQueue) Pause(p Pause) error {
+	if err := p.check(); err != nil {
+		return err
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.wal != nil {
+		if err := q.wal.append(record{Op: opPause, Pause: &p}); err != nil {
+			return err
+		}
+	}
+	return q.storage.AddPause(p)
+}
+
+// Resume lifts a pause made by Pause with the same p. Resuming something
+// that is not paused does nothing.
+func (q *JobQueue) Resume(p Pause) error {
+	if err := p.check(); err != nil {
+		return err
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.wal != nil {
+		if err := q.wal.append(record{Op: opResume, Pause: &p}); err != nil {
+			return err
+		}
+	}
+	if err := q.storage.RemovePause(p); err != nil {
+		return err
+	}
+	q.notify()
+	return nil
+}
+
+// Paused returns the pauses in effect.
+func (q *JobQueue) Paused() ([]Pause, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.storage.Pauses()
+}
+
+// unpaused returns the priorities of ps whose buckets of the named queue are
+// not paused as a whole.
+func unpaused(pauses []Pause, queue string, ps []utils.Priority) []utils.Priority {
+	out := make([]utils.Priority, 0, len(ps))
+	for _, p := range ps {
+		if !slices.ContainsFunc(pauses, func(pause Pause) bool {
+			return pause.Type == "" && pause.applies(queue, p)
+		}) {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// pausedTypes returns the job types paused in the bucket of the given queue
+// and priority.
+func pausedTypes(pauses []Pause, queue string, priority utils.Priority) []string {
+	var types []string
+	for _, pause := range pauses {
+		if pause.Type != "" && pause.applies(queue, priority) {
+			types = append(types, pause.Type)
+		}
+	}
+	return types
+}
@@ -0,0 +1,120 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Avik-creator/utils"
+)
+
+func TestPauseQueue(t *testing.T) {
+	q := NewQueue()
+	if err := q.Pause(Pause{Queue: "emails"}); err != nil {
+		t.Fatalf("Pause failed: %v", err)
+	}
+	if err := q.AddJob(utils.Job{ID: "job1", Queue: "emails", Priority: utils.High, CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("Expected a paused queue to accept jobs, got %v", err)
+	}
+	q.AddJob(utils.Job{ID: "job2", Queue: "billing", Priority: utils.Low, CreatedAt: time.Now()})
+
+	job, err := q.GetJob("emails", "billing")
+	if err != nil || job.ID != "job2" {
+		t.Fatalf("Expected job2 from the unpaused queue, got %s (%v)", job.ID, err)
+	}
+	if _, err := q.GetJob("emails"); !errors.Is(err, ErrEmpty) {
+		t.Fatalf("Expected ErrEmpty from a paused queue, got %v", err)
+	}
+
+	q.Resume(Pause{Queue: "emails"})
+	if job, err := q.GetJob("emails"); err != nil || job.ID != "job1" {
+		t.Errorf("Expected job1 once resumed, got %s (%v)", job.ID, err)
+	}
+}
+
+func TestPausePriority(t *testing.T) {
+	q := NewQueue()
+	q.AddJob(utils.Job{ID: "high", Priority: utils.High, CreatedAt: time.Now()})
+	q.AddJob(utils.Job{ID: "low", Priority: utils.Low, CreatedAt: time.Now()})
+	q.Pause(Pause{Queue: utils.DefaultQueue, Priority: utils.High})
+
+	if job, err := q.GetJob(); err != nil || job.ID != "low" {
+		t.Errorf("Expected the paused bucket to be passed over, got %s (%v)", job.ID, err)
+	}
+	if _, err := q.GetJob(); !errors.Is(err, ErrEmpty) {
+		t.Errorf("Expected ErrEmpty, got %v", err)
+	}
+}
+
+func TestPauseType(t *testing.T) {
+	q := NewQueue()
+	now := time.Now()
+	q.AddJob(utils.Job{ID: "email1", Type: "email", Priority: utils.High, CreatedAt: now})
+	q.AddJob(utils.Job{ID: "sms1", Type: "sms", Priority: utils.High, CreatedAt: now.Add(time.Millisecond)})
+	q.AddJob(utils.Job{ID: "email2", Type: "email", Priority: utils.Low, CreatedAt: now})
+	q.Pause(Pause{Type: "email"})
+
+	if job, err := q.GetJob(); err != nil || job.ID != "sms1" {
+		t.Fatalf("Expected sms1 past the paused email job, got %s (%v)", job.ID, err)
+	}
+	if _, err := q.GetJob(); !errors.Is(err, ErrEmpty) {
+		t.Fatalf("Expected only paused jobs to be left, got %v", err)
+	}
+
+	q.Resume(Pause{Type: "email"})
+	if job, _ := q.GetJob(); job.ID != "email1" {
+		t.Errorf("Expected email1 once resumed, got %s", job.ID)
+	}
+}
+
+func TestResumeWakesDequeue(t *testing.T) {
+	q := NewQueue()
+	q.Pause(Pause{Queue: utils.DefaultQueue})
+	q.AddJob(utils.Job{ID: "job1", Priority: utils.High, CreatedAt: time.Now()})
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		q.Resume(Pause{Queue: utils.DefaultQueue})
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if job, err := q.Dequeue(ctx); err != nil || job.ID != "job1" {
+		t.Errorf("Expected Resume to wake the dequeue, got %s (%v)", job.ID, err)
+	}
+}
+
+func TestInvalidPause(t *testing.T) {
+	q := NewQueue()
+	if err := q.Pause(Pause{}); !errors.Is(err, ErrEmptyPause) {
+		t.Errorf("Expected ErrEmptyPause, got %v", err)
+	}
+	if err := q.Pause(Pause{Priority: utils.MaxPriority + 1}); !errors.Is(err, ErrInvalidPriority) {
+		t.Errorf("Expected ErrInvalidPriority, got %v", err)
+	}
+}
+
+func TestPausesSurviveRestart(t *testing.T) {
+	dir := t.TempDir()
+
+	q := NewQueue(WithLog(openTestLog(t, dir)))
+	q.Pause(Pause{Queue: "emails"})
+	q.Pause(Pause{Type: "sms"})
+	if err := q.Snapshot(); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	q.Pause(Pause{Queue: "billing", Priority: utils.Low})
+	q.Resume(Pause{Type: "sms"})
+	q.Close()
+
+	q = NewQueue(WithLog(openTestLog(t, dir)))
+	defer q.Close()
+
+	got, err := q.Paused()
+	want := []Pause{{Queue: "billing", Priority: utils.Low}, {Queue: "emails"}}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected pauses %v after a restart, got %v (%v)", want, got, err)
+	}
+}
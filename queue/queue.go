@@ -1,85 +1,435 @@
 package queue
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/Avik-creator/utils"
 )
 
+// ErrEmpty is returned by the non-blocking dequeue methods when there is no
+// job to hand out.
+var ErrEmpty = errors.New("no job found")
+
+// ErrMissingID is returned for a job added or scheduled without an ID.
+var ErrMissingID = errors.New("job has no ID")
+
 type JobQueue struct {
-	mu              sync.Mutex
-	queue           map[utils.Priority][]utils.Job
-	deadLetterQueue map[utils.Priority][]utils.Job
+	mu               sync.Mutex
+	storage          Storage
+	strategy         Strategy
+	wal              *Log
+	leases           map[string]uint64
+	nextLease        uint64
+	snapshotInterval time.Duration
+	pollInterval     time.Duration
+	agingInterval    time.Duration
+	agingCeiling     utils.Priority
+	limits           []limit
+	duplicates       DuplicatePolicy
+	expiry           ExpiryPolicy
+	jobs             *registry
+	resultRetention  time.Duration
+	lastPrune        time.Time
+	lastClaimPrune   time.Time
+	// turn rotates the queue a multi-queue dequeue looks at first.
+	turn uint64
+	// ready is closed and replaced whenever a job may have become available,
+	// or room for one, waking every blocked Dequeue and AddJob.
+	ready chan struct{}
+	stop  chan struct{}
+}
+
+// ScheduledJob is a job that a Scheduler will add to the queue at
+// ScheduleTime.
+type ScheduledJob struct {
+	Job          utils.Job `json:"job"`
+	ScheduleTime time.Time `json:"schedule_time"`
+}
+
+type Option func(*JobQueue)
+
+func WithStorage(s Storage) Option {
+	return func(q *JobQueue) {
+		q.storage = s
+	}
+}
+
+// WithLog records every queue mutation in l and replays the records l
+// already holds into the storage when the queue is created. It is meant to
+// be paired with the in-memory storage.
+func WithLog(l *Log) Option {
+	return func(q *JobQueue) {
+		q.wal = l
+	}
 }
 
-func NewQueue() *JobQueue {
-	return &JobQueue{
-		queue: map[utils.Priority][]utils.Job{
-			utils.High:   make([]utils.Job, 0),
-			utils.Medium: make([]utils.Job, 0),
-			utils.Low:    make([]utils.Job, 0),
-		},
-		deadLetterQueue: map[utils.Priority][]utils.Job{
-			utils.High:   make([]utils.Job, 0),
-			utils.Medium: make([]utils.Job, 0),
-			utils.Low:    make([]utils.Job, 0),
-		},
+// WithSnapshotInterval makes a queue opened with WithLog take a snapshot and
+// compact its log every d.
+func WithSnapshotInterval(d time.Duration) Option {
+	return func(q *JobQueue) {
+		q.snapshotInterval = d
 	}
 }
 
-func (q *JobQueue) AddJob(job utils.Job) *JobQueue {
+func NewQueue(opts ...Option) *JobQueue {
+	q := &JobQueue{
+		leases:          make(map[string]uint64),
+		jobs:            newRegistry(),
+		resultRetention: DefaultResultRetention,
+		ready:           make(chan struct{}),
+		stop:            make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(q)
+	}
+	if q.storage == nil {
+		q.storage = NewMemoryStorage()
+	}
+	if q.strategy == nil {
+		q.strategy = Strict()
+	}
+	if q.wal != nil {
+		if q.wal.snapshot != nil {
+			if err := q.restore(*q.wal.snapshot); err != nil {
+				log.Printf("queue: failed to restore snapshot: %v", err)
+			}
+			q.wal.snapshot = nil
+		}
+		for _, rec := range q.wal.pending {
+			if err := q.apply(rec); err != nil {
+				log.Printf("queue: failed to replay %s of job %s: %v", rec.Op, rec.Job.ID, err)
+			}
+		}
+		q.wal.pending = nil
+
+		if q.snapshotInterval > 0 {
+			go q.snapshotLoop()
+		}
+	}
+	if err := q.seed(); err != nil {
+		log.Printf("queue: failed to load job states: %v", err)
+	}
+	return q
+}
+
+func (q *JobQueue) apply(rec record) error {
+	rec.Job = withQueue(rec.Job)
+	switch rec.Op {
+	case opAdd:
+		return q.storage.Enqueue(rec.Job)
+	case opAddBatch:
+		for i, job := range rec.Jobs {
+			rec.Jobs[i] = withQueue(job)
+		}
+		return q.storage.EnqueueBatch(rec.Jobs, nil)
+	case opSchedule:
+		return q.storage.Schedule(ScheduledJob{Job: rec.Job, ScheduleTime: rec.At}, nil)
+	case opGet:
+		if err := q.storage.Remove(rec.Job); err != nil {
+			return err
+		}
+		return q.storage.Ack(rec.Job)
+	case opRemove:
+		return q.storage.Remove(rec.Job)
+	case opLease, opHold:
+		// The job may sit in its bucket if a snapshot was taken while it
+		// was queued, so take it out before holding it.
+		if err := q.storage.Remove(rec.Job); err != nil {
+			return err
+		}
+		return q.storage.Hold(rec.Job, rec.At)
+	case opAck:
+		return q.storage.Ack(rec.Job)
+	case opDeadLetter:
+		return q.storage.MoveToDeadLetter(rec.Job)
+	case opGetDeadLetter:
+		_, _, err := q.storage.DequeueDeadLetter(rec.Job.Queue, rec.Job.Priority)
+		return err
+	case opResult:
+		if rec.Result == nil {
+			return fmt.Errorf("result record without a result")
+		}
+		return q.storage.SaveResult(*rec.Result)
+	case opPause, opResume:
+		if rec.Pause == nil {
+			return fmt.Errorf("%s record without a pause", rec.Op)
+		}
+		if rec.Op == opPause {
+			return q.storage.AddPause(*rec.Pause)
+		}
+		return q.storage.RemovePause(*rec.Pause)
+	}
+	return fmt.Errorf("unknown log operation %q", rec.Op)
+}
+
+func (q *JobQueue) record(op string, job utils.Job) error {
+	return q.recordAt(op, job, time.Time{})
+}
+
+func (q *JobQueue) recordAt(op string, job utils.Job, at time.Time) error {
+	if q.wal == nil {
+		return nil
+	}
+	return q.wal.append(record{Op: op, Job: job, At: at})
+}
+
+func checkID(job utils.Job) error {
+	if job.ID == "" {
+		return ErrMissingID
+	}
+	return nil
+}
+
+// withQueue returns job with its Queue defaulted to utils.DefaultQueue.
+func withQueue(job utils.Job) utils.Job {
+	if job.Queue == "" {
+		job.Queue = utils.DefaultQueue
+	}
+	return job
+}
+
+// queueOrder returns the queues a dequeue over names should try, in order.
+// No names means the default queue. With several names the starting queue
+// rotates from call to call, so one busy queue cannot starve the others. It
+// is called with q.mu held.
+func (q *JobQueue) queueOrder(names []string) []string {
+	if len(names) == 0 {
+		return []string{utils.DefaultQueue}
+	}
+	if len(names) == 1 {
+		return names
+	}
+	start := int(q.turn % uint64(len(names)))
+	q.turn++
+	return append(append(make([]string, 0, len(names)), names[start:]...), names[:start]...)
+}
+
+// AddJob adds job to its queue. It fails with ErrMissingID for a job without
+// an ID, with ErrInvalidPriority for a job with an invalid priority, with
+// ErrDuplicateJob when another job holds its UniqueKey, and with
+// ErrQueueFull when a capacity limit is reached; under the Block policy it
+// waits for space instead. A job that has already expired is discarded or
+// dead-lettered as WithExpiry says, and AddJob returns ErrJobExpired.
+func (q *JobQueue) AddJob(job utils.Job) error {
+	return q.AddJobContext(context.Background(), job)
+}
+
+func (q *JobQueue) addJob(job utils.Job) error {
+	now := time.Now()
+	ok, err := q.admit(job, nil, now)
+	if err != nil || !ok {
+		return err
+	}
+	if err := q.record(opAdd, job); err != nil {
+		return err
+	}
+	if err := q.storage.EnqueueBatch([]utils.Job{job}, q.claims([]utils.Job{job}, now)); err != nil {
+		return q.merged(err)
+	}
+	q.jobs.set(job, utils.Queued)
+	q.notify()
+	return nil
+}
+
+// admit checks that job may be added, expiring it or making room for it as
+// needed, and reports whether it is to be added at all: a merged duplicate
+// is not. pending are the jobs of the same batch admitted before it, which
+// count towards capacity limits but are not stored yet. It is called with
+// q.mu held.
+func (q *JobQueue) admit(job utils.Job, pending []utils.Job, now time.Time) (bool, error) {
+	if err := q.jobs.check(job.ID, utils.Queued); err != nil {
+		return false, err
+	}
+	if job.Expired(now) {
+		if err := q.expire(job); err != nil {
+			return false, err
+		}
+		return false, errExpired(job)
+	}
+	if err := q.checkUnique(job); err != nil {
+		if errors.Is(err, errMerged) {
+			return false, nil
+		}
+		return false, err
+	}
+	if err := q.makeRoom(job, pending); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Schedule records that job is due to be added at at. The schedule is kept
+// by the storage, so it survives a restart and, with a storage shared between
+// processes, is seen by all of them; a Scheduler takes it with TakeDueJobs
+// and calls AddJob when it becomes due. Like AddJob it fails with
+// ErrDuplicateJob when another job holds job's UniqueKey.
+func (q *JobQueue) Schedule(job utils.Job, at time.Time) error {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
-	switch job.Priority {
-	case utils.High:
-		q.queue[utils.High] = append(q.queue[utils.High], job)
-	case utils.Medium:
-		q.queue[utils.Medium] = append(q.queue[utils.Medium], job)
-	case utils.Low:
-		q.queue[utils.Low] = append(q.queue[utils.Low], job)
+	job = withQueue(job)
+	if err := checkID(job); err != nil {
+		return err
 	}
-	return q
+	if err := checkPriority(job); err != nil {
+		return err
+	}
+	if err := q.jobs.check(job.ID, utils.Scheduled); err != nil {
+		return err
+	}
+	if err := q.checkUnique(job); err != nil {
+		if errors.Is(err, errMerged) {
+			return nil
+		}
+		return err
+	}
+	if err := q.recordAt(opSchedule, job, at); err != nil {
+		return err
+	}
+	if err := q.storage.Schedule(ScheduledJob{Job: job, ScheduleTime: at}, q.claims([]utils.Job{job}, time.Now())); err != nil {
+		return q.merged(err)
+	}
+	q.jobs.set(job, utils.Scheduled)
+	return nil
 }
 
-func (q *JobQueue) GetJob() (utils.Job, error) {
+// ScheduledJobs returns the jobs scheduled for later, soonest first, leaving
+// out those that have expired.
+func (q *JobQueue) ScheduledJobs() []ScheduledJob {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
-	if len(q.queue[utils.High]) > 0 {
-		job := q.queue[utils.High][0]
-		q.queue[utils.High] = q.queue[utils.High][1:]
-		return job, nil
+	jobs, err := q.storage.ScheduledJobs()
+	if err != nil {
+		log.Printf("queue: failed to list scheduled jobs: %v", err)
 	}
+	now := time.Now()
+	return slices.DeleteFunc(jobs, func(sj ScheduledJob) bool { return sj.Job.Expired(now) })
+}
+
+// TakeDueJobs takes the jobs due by now off the schedule and returns them,
+// soonest first, for the caller to add with AddJob. Jobs that expired before
+// they were due are taken too, so that AddJob expires them as WithExpiry
+// says. Processes sharing a storage never take the same job.
+func (q *JobQueue) TakeDueJobs(now time.Time) ([]ScheduledJob, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.storage.TakeDue(now)
+}
+
+// RetryJob makes job available again once delay has passed. Until then the
+// job is held in flight, so a crash during the delay does not lose it.
+func (q *JobQueue) RetryJob(job utils.Job, delay time.Duration) *JobQueue {
+	q.mu.Lock()
+	defer q.mu.Unlock()
 
-	if len(q.queue[utils.Medium]) > 0 {
-		job := q.queue[utils.Medium][0]
-		q.queue[utils.Medium] = q.queue[utils.Medium][1:]
-		return job, nil
+	job = withQueue(job)
+	if err := q.jobs.check(job.ID, utils.Retrying); err != nil {
+		log.Printf("queue: %v", err)
+		return q
 	}
+	if err := q.hold(job, time.Now().Add(delay)); err != nil {
+		log.Printf("queue: failed to hold job %s for retry: %v", job.ID, err)
+		return q
+	}
+	q.jobs.set(job, utils.Retrying)
+	return q
+}
 
-	if len(q.queue[utils.Low]) > 0 {
-		job := q.queue[utils.Low][0]
-		q.queue[utils.Low] = q.queue[utils.Low][1:]
-		return job, nil
+// GetJob takes the next job by priority from the named queues, or from the
+// default queue when none are given.
+func (q *JobQueue) GetJob(queues ...string) (utils.Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.getJob(queues)
+}
+
+func (q *JobQueue) getJob(queues []string) (utils.Job, error) {
+	q.requeueExpired()
+	job, ok, err := q.dequeue(queues, time.Time{})
+	if err != nil {
+		return utils.Job{}, err
+	}
+	if !ok {
+		return utils.Job{}, ErrEmpty
+	}
+	if err := q.record(opGet, job); err != nil {
+		if err := q.storage.Enqueue(job); err != nil {
+			log.Printf("queue: failed to requeue job %s: %v", job.ID, err)
+		}
+		return utils.Job{}, err
 	}
+	// Nothing reports back on a job taken without a lease, so it must not
+	// hold its unique key for ever.
+	q.jobs.set(job, utils.Delivered)
+	q.freed()
+	return job, nil
+}
 
-	return utils.Job{}, errors.New("no job found")
+// dequeue takes a job from the first of the named queues that has one, from
+// the priority the strategy picks after aging, holding it until leaseUntil as
+// Storage.Dequeue does. Paused buckets and job types are left alone, and
+// expired jobs it comes across are expired and passed over. It is called with
+// q.mu held.
+func (q *JobQueue) dequeue(queues []string, leaseUntil time.Time) (utils.Job, bool, error) {
+	pauses, err := q.storage.Pauses()
+	if err != nil {
+		return utils.Job{}, false, err
+	}
+	for _, name := range q.queueOrder(queues) {
+		ps, err := q.storage.Priorities(name)
+		if err != nil {
+			return utils.Job{}, false, err
+		}
+		ps = unpaused(pauses, name, ps)
+		for len(ps) > 0 {
+			p, err := q.pick(name, ps)
+			if err != nil {
+				return utils.Job{}, false, err
+			}
+			job, ok, err := q.storage.DequeueSkipping(name, p, pausedTypes(pauses, name, p), leaseUntil)
+			if err != nil {
+				return utils.Job{}, false, err
+			}
+			if !ok {
+				// Empty, or holding only paused types.
+				ps = slices.DeleteFunc(ps, func(x utils.Priority) bool { return x == p })
+				continue
+			}
+			if !job.Expired(time.Now()) {
+				return job, true, nil
+			}
+			if err := q.expire(job); err != nil {
+				return utils.Job{}, false, err
+			}
+		}
+	}
+	return utils.Job{}, false, nil
 }
 
 func (q *JobQueue) RemoveJobFromQueue(job utils.Job) *JobQueue {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
-	switch job.Priority {
-	case utils.High:
-		q.queue[utils.High] = utils.RemoveJob(q.queue[utils.High], job)
-	case utils.Medium:
-		q.queue[utils.Medium] = utils.RemoveJob(q.queue[utils.Medium], job)
-	case utils.Low:
-		q.queue[utils.Low] = utils.RemoveJob(q.queue[utils.Low], job)
+	job = withQueue(job)
+	if err := q.record(opRemove, job); err != nil {
+		log.Printf("queue: failed to log removal of job %s: %v", job.ID, err)
 	}
+	if err := q.storage.Remove(job); err != nil {
+		log.Printf("queue: failed to remove job %s: %v", job.ID, err)
+	}
+	q.jobs.forget(job.ID)
+	q.freed()
 	return q
 }
 
@@ -87,64 +437,133 @@ func (q *JobQueue) MoveJobToDeadLetterQueue(job utils.Job) *JobQueue {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
-	// First remove from regular queue
-	switch job.Priority {
-	case utils.High:
-		q.queue[utils.High] = utils.RemoveJob(q.queue[utils.High], job)
-		q.deadLetterQueue[utils.High] = append(q.deadLetterQueue[utils.High], job)
-	case utils.Medium:
-		q.queue[utils.Medium] = utils.RemoveJob(q.queue[utils.Medium], job)
-		q.deadLetterQueue[utils.Medium] = append(q.deadLetterQueue[utils.Medium], job)
-	case utils.Low:
-		q.queue[utils.Low] = utils.RemoveJob(q.queue[utils.Low], job)
-		q.deadLetterQueue[utils.Low] = append(q.deadLetterQueue[utils.Low], job)
+	job = withQueue(job)
+	if err := q.jobs.check(job.ID, utils.Dead); err != nil {
+		log.Printf("queue: %v", err)
+		return q
+	}
+	if err := q.record(opDeadLetter, job); err != nil {
+		log.Printf("queue: failed to log dead-lettering of job %s: %v", job.ID, err)
 	}
+	delete(q.leases, job.ID)
+	if err := q.storage.MoveToDeadLetter(job); err != nil {
+		log.Printf("queue: failed to move job %s to dead-letter queue: %v", job.ID, err)
+	}
+	q.jobs.set(job, utils.Dead)
+	q.freed()
 	return q
 }
 
-func (q *JobQueue) GetDeadLetterJob() (utils.Job, error) {
+func (q *JobQueue) GetDeadLetterJob(queues ...string) (utils.Job, error) {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
-	if len(q.deadLetterQueue[utils.High]) > 0 {
-		job := q.deadLetterQueue[utils.High][0]
-		q.deadLetterQueue[utils.High] = q.deadLetterQueue[utils.High][1:]
-		return job, nil
+	for _, name := range q.queueOrder(queues) {
+		ps, err := q.storage.DeadLetterPriorities(name)
+		if err != nil {
+			return utils.Job{}, err
+		}
+		for _, p := range ps {
+			job, ok, err := q.storage.DequeueDeadLetter(name, p)
+			if err != nil {
+				return utils.Job{}, err
+			}
+			if ok {
+				if err := q.record(opGetDeadLetter, job); err != nil {
+					log.Printf("queue: failed to log removal of dead-letter job %s: %v", job.ID, err)
+				}
+				return job, nil
+			}
+		}
 	}
 
-	if len(q.deadLetterQueue[utils.Medium]) > 0 {
-		job := q.deadLetterQueue[utils.Medium][0]
-		q.deadLetterQueue[utils.Medium] = q.deadLetterQueue[utils.Medium][1:]
-		return job, nil
-	}
+	return utils.Job{}, ErrEmpty
+}
 
-	if len(q.deadLetterQueue[utils.Low]) > 0 {
-		job := q.deadLetterQueue[utils.Low][0]
-		q.deadLetterQueue[utils.Low] = q.deadLetterQueue[utils.Low][1:]
-		return job, nil
-	}
+// GetAllJobs lists the queued jobs of the named queues, or of the default
+// queue when none are given, split into High, Medium and Low. Jobs with a
+// priority value above Low are listed with Low, in priority order.
+func (q *JobQueue) GetAllJobs(queues ...string) ([]utils.Job, []utils.Job, []utils.Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
 
-	return utils.Job{}, errors.New("no job found")
+	q.requeueExpired()
+	return listByPriority(queues, q.storage.Priorities, q.storage.List)
 }
 
-func (q *JobQueue) GetAllJobs() ([]utils.Job, []utils.Job, []utils.Job, error) {
+func (q *JobQueue) GetAllDeadLetterJobs(queues ...string) ([]utils.Job, []utils.Job, []utils.Job, error) {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
-	highJobs := q.queue[utils.High]
-	mediumJobs := q.queue[utils.Medium]
-	lowJobs := q.queue[utils.Low]
-
-	return highJobs, mediumJobs, lowJobs, nil
+	return listByPriority(queues, q.storage.DeadLetterPriorities, q.storage.ListDeadLetter)
 }
 
-func (q *JobQueue) GetAllDeadLetterJobs() ([]utils.Job, []utils.Job, []utils.Job, error) {
+// Queues returns the sorted names of the queues that hold jobs.
+func (q *JobQueue) Queues() ([]string, error) {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
-	highJobs := q.deadLetterQueue[utils.High]
-	mediumJobs := q.deadLetterQueue[utils.Medium]
-	lowJobs := q.deadLetterQueue[utils.Low]
+	return q.storage.Queues()
+}
+
+func listByPriority(queues []string, levels func(string) ([]utils.Priority, error), list func(string, utils.Priority) ([]utils.Job, error)) ([]utils.Job, []utils.Job, []utils.Job, error) {
+	if len(queues) == 0 {
+		queues = []string{utils.DefaultQueue}
+	}
+
+	highJobs, mediumJobs, lowJobs := make([]utils.Job, 0), make([]utils.Job, 0), make([]utils.Job, 0)
+	byPriority := make(map[utils.Priority][]utils.Job)
+	for _, name := range queues {
+		ps, err := levels(name)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		for _, p := range ps {
+			jobs, err := list(name, p)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			byPriority[p] = append(byPriority[p], jobs...)
+		}
+	}
+
+	ps := make([]utils.Priority, 0, len(byPriority))
+	for p := range byPriority {
+		ps = append(ps, p)
+	}
+	sort.Slice(ps, func(i, j int) bool { return ps[i] < ps[j] })
+	for _, p := range ps {
+		switch {
+		case p <= utils.High:
+			highJobs = append(highJobs, byPriority[p]...)
+		case p == utils.Medium:
+			mediumJobs = append(mediumJobs, byPriority[p]...)
+		default:
+			lowJobs = append(lowJobs, byPriority[p]...)
+		}
+	}
 
 	return highJobs, mediumJobs, lowJobs, nil
 }
+
+func (q *JobQueue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	select {
+	case <-q.stop:
+		return nil
+	default:
+		close(q.stop)
+	}
+	var err error
+	if q.wal != nil {
+		err = q.wal.Close()
+	}
+	if c, ok := q.storage.(io.Closer); ok {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
@@ -1,6 +1,7 @@
 package queue
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
 	"sync"
@@ -17,34 +18,37 @@ func TestNewQueue(t *testing.T) {
 		t.Fatal("NewQueue returned nil")
 	}
 
-	if q.queue == nil {
+	m, ok := q.storage.(*MemoryStorage)
+	if !ok {
+		t.Fatalf("Expected default storage to be *MemoryStorage, got %T", q.storage)
+	}
+
+	if m.queue == nil {
 		t.Fatal("queue map not initialized")
 	}
 
-	if q.deadLetterQueue == nil {
+	if m.deadLetterQueue == nil {
 		t.Fatal("deadLetterQueue map not initialized")
 	}
 
-	// Check that all priority queues are initialized
+	// Check that the default queue is initialized and empty
+	if m.queue[utils.DefaultQueue] == nil || m.deadLetterQueue[utils.DefaultQueue] == nil {
+		t.Fatal("default queue not initialized")
+	}
 	expectedPriorities := []utils.Priority{utils.High, utils.Medium, utils.Low}
 	for _, priority := range expectedPriorities {
-		if _, exists := q.queue[priority]; !exists {
-			t.Errorf("queue not initialized for priority %v", priority)
-		}
-		if _, exists := q.deadLetterQueue[priority]; !exists {
-			t.Errorf("deadLetterQueue not initialized for priority %v", priority)
-		}
-		if len(q.queue[priority]) != 0 {
-			t.Errorf("queue for priority %v should be empty, got %d items", priority, len(q.queue[priority]))
+		if m.queue[utils.DefaultQueue].buckets[priority].len() != 0 {
+			t.Errorf("queue for priority %v should be empty, got %d items", priority, m.queue[utils.DefaultQueue].buckets[priority].len())
 		}
-		if len(q.deadLetterQueue[priority]) != 0 {
-			t.Errorf("deadLetterQueue for priority %v should be empty, got %d items", priority, len(q.deadLetterQueue[priority]))
+		if m.deadLetterQueue[utils.DefaultQueue].buckets[priority].len() != 0 {
+			t.Errorf("deadLetterQueue for priority %v should be empty, got %d items", priority, m.deadLetterQueue[utils.DefaultQueue].buckets[priority].len())
 		}
 	}
 }
 
 func TestAddJob(t *testing.T) {
 	q := NewQueue()
+	m := q.storage.(*MemoryStorage)
 
 	// Create test jobs
 	job1 := utils.Job{
@@ -83,25 +87,25 @@ func TestAddJob(t *testing.T) {
 	q.AddJob(job3)
 
 	// Verify jobs were added to correct queues
-	if len(q.queue[utils.High]) != 1 {
-		t.Errorf("Expected 1 high priority job, got %d", len(q.queue[utils.High]))
+	if m.queue[utils.DefaultQueue].buckets[utils.High].len() != 1 {
+		t.Errorf("Expected 1 high priority job, got %d", m.queue[utils.DefaultQueue].buckets[utils.High].len())
 	}
-	if len(q.queue[utils.Medium]) != 1 {
-		t.Errorf("Expected 1 medium priority job, got %d", len(q.queue[utils.Medium]))
+	if m.queue[utils.DefaultQueue].buckets[utils.Medium].len() != 1 {
+		t.Errorf("Expected 1 medium priority job, got %d", m.queue[utils.DefaultQueue].buckets[utils.Medium].len())
 	}
-	if len(q.queue[utils.Low]) != 1 {
-		t.Errorf("Expected 1 low priority job, got %d", len(q.queue[utils.Low]))
+	if m.queue[utils.DefaultQueue].buckets[utils.Low].len() != 1 {
+		t.Errorf("Expected 1 low priority job, got %d", m.queue[utils.DefaultQueue].buckets[utils.Low].len())
 	}
 
 	// Verify job content
-	if q.queue[utils.High][0].ID != "job1" {
-		t.Errorf("Expected job1 in high priority queue, got %s", q.queue[utils.High][0].ID)
+	if m.queue[utils.DefaultQueue].buckets[utils.High].jobs()[0].ID != "job1" {
+		t.Errorf("Expected job1 in high priority queue, got %s", m.queue[utils.DefaultQueue].buckets[utils.High].jobs()[0].ID)
 	}
-	if q.queue[utils.Medium][0].ID != "job2" {
-		t.Errorf("Expected job2 in medium priority queue, got %s", q.queue[utils.Medium][0].ID)
+	if m.queue[utils.DefaultQueue].buckets[utils.Medium].jobs()[0].ID != "job2" {
+		t.Errorf("Expected job2 in medium priority queue, got %s", m.queue[utils.DefaultQueue].buckets[utils.Medium].jobs()[0].ID)
 	}
-	if q.queue[utils.Low][0].ID != "job3" {
-		t.Errorf("Expected job3 in low priority queue, got %s", q.queue[utils.Low][0].ID)
+	if m.queue[utils.DefaultQueue].buckets[utils.Low].jobs()[0].ID != "job3" {
+		t.Errorf("Expected job3 in low priority queue, got %s", m.queue[utils.DefaultQueue].buckets[utils.Low].jobs()[0].ID)
 	}
 }
 
@@ -113,8 +117,8 @@ func TestGetJob(t *testing.T) {
 	if err == nil {
 		t.Error("Expected error when getting job from empty queue")
 	}
-	if err.Error() != "no job found" {
-		t.Errorf("Expected 'no job found' error, got '%s'", err.Error())
+	if !errors.Is(err, ErrEmpty) {
+		t.Errorf("Expected ErrEmpty, got '%v'", err)
 	}
 
 	// Add jobs with different priorities
@@ -162,6 +166,7 @@ func TestGetJob(t *testing.T) {
 
 func TestRemoveJobFromQueue(t *testing.T) {
 	q := NewQueue()
+	m := q.storage.(*MemoryStorage)
 
 	job1 := utils.Job{ID: "job1", Priority: utils.High, CreatedAt: time.Now()}
 	job2 := utils.Job{ID: "job2", Priority: utils.High, CreatedAt: time.Now()}
@@ -172,31 +177,32 @@ func TestRemoveJobFromQueue(t *testing.T) {
 	q.AddJob(job3)
 
 	// Verify jobs added
-	if len(q.queue[utils.High]) != 2 {
-		t.Errorf("Expected 2 high priority jobs, got %d", len(q.queue[utils.High]))
+	if m.queue[utils.DefaultQueue].buckets[utils.High].len() != 2 {
+		t.Errorf("Expected 2 high priority jobs, got %d", m.queue[utils.DefaultQueue].buckets[utils.High].len())
 	}
-	if len(q.queue[utils.Medium]) != 1 {
-		t.Errorf("Expected 1 medium priority job, got %d", len(q.queue[utils.Medium]))
+	if m.queue[utils.DefaultQueue].buckets[utils.Medium].len() != 1 {
+		t.Errorf("Expected 1 medium priority job, got %d", m.queue[utils.DefaultQueue].buckets[utils.Medium].len())
 	}
 
 	// Remove job1 from high priority queue
 	q.RemoveJobFromQueue(job1)
 
-	if len(q.queue[utils.High]) != 1 {
-		t.Errorf("Expected 1 high priority job after removal, got %d", len(q.queue[utils.High]))
+	if m.queue[utils.DefaultQueue].buckets[utils.High].len() != 1 {
+		t.Errorf("Expected 1 high priority job after removal, got %d", m.queue[utils.DefaultQueue].buckets[utils.High].len())
 	}
-	if q.queue[utils.High][0].ID != "job2" {
-		t.Errorf("Expected job2 to remain in high priority queue, got %s", q.queue[utils.High][0].ID)
+	if m.queue[utils.DefaultQueue].buckets[utils.High].jobs()[0].ID != "job2" {
+		t.Errorf("Expected job2 to remain in high priority queue, got %s", m.queue[utils.DefaultQueue].buckets[utils.High].jobs()[0].ID)
 	}
 
 	// Medium priority queue should remain unchanged
-	if len(q.queue[utils.Medium]) != 1 {
-		t.Errorf("Expected 1 medium priority job to remain unchanged, got %d", len(q.queue[utils.Medium]))
+	if m.queue[utils.DefaultQueue].buckets[utils.Medium].len() != 1 {
+		t.Errorf("Expected 1 medium priority job to remain unchanged, got %d", m.queue[utils.DefaultQueue].buckets[utils.Medium].len())
 	}
 }
 
 func TestMoveJobToDeadLetterQueue(t *testing.T) {
 	q := NewQueue()
+	m := q.storage.(*MemoryStorage)
 
 	job1 := utils.Job{ID: "job1", Priority: utils.High, CreatedAt: time.Now()}
 	job2 := utils.Job{ID: "job2", Priority: utils.Medium, CreatedAt: time.Now()}
@@ -208,21 +214,21 @@ func TestMoveJobToDeadLetterQueue(t *testing.T) {
 	q.MoveJobToDeadLetterQueue(job1)
 
 	// Verify job1 is in dead letter queue
-	if len(q.deadLetterQueue[utils.High]) != 1 {
-		t.Errorf("Expected 1 job in high priority dead letter queue, got %d", len(q.deadLetterQueue[utils.High]))
+	if m.deadLetterQueue[utils.DefaultQueue].buckets[utils.High].len() != 1 {
+		t.Errorf("Expected 1 job in high priority dead letter queue, got %d", m.deadLetterQueue[utils.DefaultQueue].buckets[utils.High].len())
 	}
-	if q.deadLetterQueue[utils.High][0].ID != "job1" {
-		t.Errorf("Expected job1 in dead letter queue, got %s", q.deadLetterQueue[utils.High][0].ID)
+	if m.deadLetterQueue[utils.DefaultQueue].buckets[utils.High].jobs()[0].ID != "job1" {
+		t.Errorf("Expected job1 in dead letter queue, got %s", m.deadLetterQueue[utils.DefaultQueue].buckets[utils.High].jobs()[0].ID)
 	}
 
 	// Verify job1 is removed from regular queue
-	if len(q.queue[utils.High]) != 0 {
-		t.Errorf("Expected 0 jobs in high priority queue after move, got %d", len(q.queue[utils.High]))
+	if m.queue[utils.DefaultQueue].buckets[utils.High].len() != 0 {
+		t.Errorf("Expected 0 jobs in high priority queue after move, got %d", m.queue[utils.DefaultQueue].buckets[utils.High].len())
 	}
 
 	// Verify job2 remains in regular queue
-	if len(q.queue[utils.Medium]) != 1 {
-		t.Errorf("Expected 1 job in medium priority queue, got %d", len(q.queue[utils.Medium]))
+	if m.queue[utils.DefaultQueue].buckets[utils.Medium].len() != 1 {
+		t.Errorf("Expected 1 job in medium priority queue, got %d", m.queue[utils.DefaultQueue].buckets[utils.Medium].len())
 	}
 }
 
@@ -350,6 +356,7 @@ func TestGetAllDeadLetterJobs(t *testing.T) {
 
 func TestConcurrencySafety(t *testing.T) {
 	q := NewQueue()
+	m := q.storage.(*MemoryStorage)
 	const numGoroutines = 10
 	const jobsPerGoroutine = 100
 
@@ -375,8 +382,8 @@ func TestConcurrencySafety(t *testing.T) {
 
 	// Verify all jobs were added
 	totalJobs := 0
-	for _, jobs := range q.queue {
-		totalJobs += len(jobs)
+	for _, jobs := range m.queue[utils.DefaultQueue].buckets {
+		totalJobs += jobs.len()
 	}
 
 	expectedTotal := numGoroutines * jobsPerGoroutine
@@ -403,8 +410,8 @@ func TestConcurrencySafety(t *testing.T) {
 
 	// Verify all jobs were removed
 	totalJobs = 0
-	for _, jobs := range q.queue {
-		totalJobs += len(jobs)
+	for _, jobs := range m.queue[utils.DefaultQueue].buckets {
+		totalJobs += jobs.len()
 	}
 
 	if totalJobs != 0 {
@@ -414,22 +421,195 @@ func TestConcurrencySafety(t *testing.T) {
 
 func TestMethodChaining(t *testing.T) {
 	q := NewQueue()
+	m := q.storage.(*MemoryStorage)
 
 	job1 := utils.Job{ID: "job1", Priority: utils.High, CreatedAt: time.Now()}
 	job2 := utils.Job{ID: "job2", Priority: utils.Medium, CreatedAt: time.Now()}
 
+	q.AddJob(job1)
+	q.AddJob(job2)
+
 	// Test method chaining
-	result := q.AddJob(job1).AddJob(job2).RemoveJobFromQueue(job1).MoveJobToDeadLetterQueue(job2)
+	result := q.RemoveJobFromQueue(job1).MoveJobToDeadLetterQueue(job2)
 
 	if result != q {
 		t.Error("Method chaining should return the same queue instance")
 	}
 
 	// Verify final state
-	if len(q.queue[utils.High]) != 0 {
+	if m.queue[utils.DefaultQueue].buckets[utils.High].len() != 0 {
 		t.Error("High priority queue should be empty after removing job1")
 	}
-	if len(q.deadLetterQueue[utils.Medium]) != 1 {
+	if m.deadLetterQueue[utils.DefaultQueue].buckets[utils.Medium].len() != 1 {
 		t.Error("Medium priority dead letter queue should have 1 job")
 	}
 }
+
+func TestNewQueueWithStorage(t *testing.T) {
+	s := NewMemoryStorage()
+	q := NewQueue(WithStorage(s))
+
+	job := utils.Job{ID: "job1", Priority: utils.Medium, CreatedAt: time.Now()}
+	q.AddJob(job)
+
+	if s.queue[utils.DefaultQueue].buckets[utils.Medium].len() != 1 {
+		t.Fatalf("Expected job to be stored in the provided storage, got %d jobs", s.queue[utils.DefaultQueue].buckets[utils.Medium].len())
+	}
+
+	got, err := q.GetJob()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got.ID != "job1" {
+		t.Errorf("Expected job1, got %s", got.ID)
+	}
+	if s.queue[utils.DefaultQueue].buckets[utils.Medium].len() != 0 {
+		t.Errorf("Expected storage to be empty after GetJob, got %d jobs", s.queue[utils.DefaultQueue].buckets[utils.Medium].len())
+	}
+}
+
+func TestNamedQueues(t *testing.T) {
+	q := NewQueue()
+
+	q.AddJob(utils.Job{ID: "reset", Queue: "emails", Priority: utils.High, CreatedAt: time.Now()})
+	q.AddJob(utils.Job{ID: "report", Queue: "reports", Priority: utils.High, CreatedAt: time.Now()})
+	q.AddJob(utils.Job{ID: "plain", Priority: utils.Low, CreatedAt: time.Now()})
+
+	job, err := q.GetJob()
+	if err != nil || job.ID != "plain" || job.Queue != utils.DefaultQueue {
+		t.Errorf("Expected plain from the default queue, got %+v (%v)", job, err)
+	}
+	if _, err := q.GetJob(); !errors.Is(err, ErrEmpty) {
+		t.Errorf("Expected named queues to be hidden from the default queue, got %v", err)
+	}
+
+	names, err := q.Queues()
+	if err != nil || !reflect.DeepEqual(names, []string{"emails", "reports"}) {
+		t.Errorf("Expected emails and reports, got %v (%v)", names, err)
+	}
+
+	job, err = q.GetJob("emails")
+	if err != nil || job.ID != "reset" {
+		t.Errorf("Expected reset from emails, got %s (%v)", job.ID, err)
+	}
+
+	q.MoveJobToDeadLetterQueue(utils.Job{ID: "report", Queue: "reports", Priority: utils.High})
+	if dead, _, _, _ := q.GetAllDeadLetterJobs("emails"); len(dead) != 0 {
+		t.Errorf("Expected no dead-letter jobs in emails, got %v", dead)
+	}
+	if dead, _, _, _ := q.GetAllDeadLetterJobs("reports"); len(dead) != 1 || dead[0].ID != "report" {
+		t.Errorf("Expected report in the reports dead letter queue, got %v", dead)
+	}
+}
+
+func TestGetJobRotatesQueues(t *testing.T) {
+	q := NewQueue()
+
+	for i := 0; i < 3; i++ {
+		q.AddJob(utils.Job{ID: fmt.Sprintf("email-%d", i), Queue: "emails", Priority: utils.Low, CreatedAt: time.Now()})
+		q.AddJob(utils.Job{ID: fmt.Sprintf("report-%d", i), Queue: "reports", Priority: utils.High, CreatedAt: time.Now()})
+	}
+
+	counts := make(map[string]int)
+	for i := 0; i < 4; i++ {
+		job, err := q.GetJob("emails", "reports")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		counts[job.Queue]++
+	}
+	if counts["emails"] != 2 || counts["reports"] != 2 {
+		t.Errorf("Expected both queues to be served in turn, got %v", counts)
+	}
+}
+
+func TestArbitraryPriorities(t *testing.T) {
+	q := NewQueue()
+	now := time.Now()
+
+	q.AddJob(utils.Job{ID: "p500", Priority: 500, CreatedAt: now})
+	q.AddJob(utils.Job{ID: "p5-late", Priority: 5, CreatedAt: now})
+	q.AddJob(utils.Job{ID: "p5-early", Priority: 5, CreatedAt: now.Add(-time.Second)})
+	q.AddJob(utils.Job{ID: "p5-tie", Priority: 5, CreatedAt: now})
+	q.AddJob(utils.Job{ID: "medium", Priority: utils.Medium, CreatedAt: now})
+
+	_, mediumJobs, lowJobs, err := q.GetAllJobs()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(mediumJobs) != 1 || len(lowJobs) != 4 || lowJobs[3].ID != "p500" {
+		t.Errorf("Expected priorities above Low to be listed with Low, got medium=%v low=%v", mediumJobs, lowJobs)
+	}
+
+	expected := []string{"medium", "p5-early", "p5-late", "p5-tie", "p500"}
+	for _, id := range expected {
+		job, err := q.GetJob()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if job.ID != id {
+			t.Errorf("Expected %s, got %s", id, job.ID)
+		}
+	}
+}
+
+func TestRejectsInvalidPriority(t *testing.T) {
+	q := NewQueue()
+
+	for _, job := range []utils.Job{
+		{ID: "unset", CreatedAt: time.Now()},
+		{ID: "too-low", Priority: utils.MaxPriority + 1, CreatedAt: time.Now()},
+	} {
+		if err := q.AddJob(job); !errors.Is(err, ErrInvalidPriority) {
+			t.Errorf("Expected ErrInvalidPriority from AddJob for %s, got %v", job.ID, err)
+		}
+	}
+	if _, err := q.GetJob(); !errors.Is(err, ErrEmpty) {
+		t.Errorf("Expected jobs with invalid priorities not to be queued, got %v", err)
+	}
+
+	if err := q.Schedule(utils.Job{ID: "unset"}, time.Now()); !errors.Is(err, ErrInvalidPriority) {
+		t.Errorf("Expected ErrInvalidPriority from Schedule, got %v", err)
+	}
+	if err := q.storage.Enqueue(utils.Job{ID: "negative", Queue: utils.DefaultQueue, Priority: -1}); !errors.Is(err, ErrInvalidPriority) {
+		t.Errorf("Expected ErrInvalidPriority from the storage, got %v", err)
+	}
+}
+
+func TestRejectsMissingID(t *testing.T) {
+	q := NewQueue()
+
+	if err := q.AddJob(utils.Job{Priority: utils.High}); !errors.Is(err, ErrMissingID) {
+		t.Errorf("Expected ErrMissingID from AddJob, got %v", err)
+	}
+	if err := q.AddJobs([]utils.Job{{ID: "job1", Priority: utils.High}, {Priority: utils.High}}); !errors.Is(err, ErrMissingID) {
+		t.Errorf("Expected ErrMissingID from AddJobs, got %v", err)
+	}
+	if err := q.Schedule(utils.Job{Priority: utils.High}, time.Now()); !errors.Is(err, ErrMissingID) {
+		t.Errorf("Expected ErrMissingID from Schedule, got %v", err)
+	}
+	if _, err := q.GetJob(); !errors.Is(err, ErrEmpty) {
+		t.Errorf("Expected no job to be queued, got %v", err)
+	}
+}
+
+func TestTakeDueJobs(t *testing.T) {
+	q := NewQueue()
+
+	now := time.Now()
+	q.Schedule(utils.Job{ID: "later", Priority: utils.High}, now.Add(time.Minute))
+	q.Schedule(utils.Job{ID: "soon", Priority: utils.High}, now)
+	q.Schedule(utils.Job{ID: "removed", Priority: utils.High}, now)
+	q.RemoveJobFromQueue(utils.Job{ID: "removed", Priority: utils.High})
+
+	due, err := q.TakeDueJobs(now)
+	if err != nil || len(due) != 1 || due[0].Job.ID != "soon" {
+		t.Fatalf("Expected to take soon, got %v (%v)", due, err)
+	}
+	if due, _ := q.TakeDueJobs(now); len(due) != 0 {
+		t.Errorf("Expected soon to be taken only once, got %v", due)
+	}
+	if scheduled := q.ScheduledJobs(); len(scheduled) != 1 || scheduled[0].Job.ID != "later" {
+		t.Errorf("Expected later to stay scheduled, got %v", scheduled)
+	}
+}
@@ -0,0 +1,228 @@
+package queue
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Avik-creator/utils"
+)
+
+// DefaultHistoryRetention is how long GetJobByID remembers a job after it
+// succeeded, was delivered or died.
+const DefaultHistoryRetention = time.Hour
+
+var (
+	// ErrUnknownJob is returned by GetJobByID for a job the queue does not
+	// know about.
+	ErrUnknownJob = errors.New("unknown job")
+	// ErrInvalidTransition is returned when an operation would move a job
+	// to a state it cannot reach from its current one, such as adding a job
+	// that already succeeded.
+	ErrInvalidTransition = errors.New("invalid job state transition")
+)
+
+// JobInfo is what the queue knows about a job: the job itself, while the
+// queue still holds it, its current state and every state it went through.
+type JobInfo struct {
+	Job     utils.Job
+	State   utils.JobState
+	History []Transition
+}
+
+// Transition is a job entering State at At.
+type Transition struct {
+	State utils.JobState `json:"state"`
+	At    time.Time      `json:"at"`
+}
+
+// WithHistoryRetention sets how long GetJobByID remembers jobs that
+// succeeded, were delivered or died. The default is DefaultHistoryRetention.
+func WithHistoryRetention(d time.Duration) Option {
+	return func(q *JobQueue) {
+		q.jobs.retention = d
+	}
+}
+
+// registry tracks the state of every job the queue knows about. It lives in
+// memory; NewQueue seeds it from the storage, so after a restart a job's
+// history starts from the state it was found in. It keeps no copy of the
+// jobs themselves, whose payloads the storage already holds.
+type registry struct {
+	jobs      map[string]*entry
+	retention time.Duration
+	// finished holds the IDs of succeeded, delivered and dead jobs, oldest
+	// first, so that they can be forgotten once retention has passed.
+	finished *list.List
+	// keys maps each unique key to the job holding it; see WithUniqueness.
+	keys      map[string]claim
+	window    time.Duration
+	lastSweep time.Time
+}
+
+// entry is what the registry keeps of a job.
+type entry struct {
+	uniqueKey string
+	state     utils.JobState
+	history   []Transition
+}
+
+type finishedJob struct {
+	id string
+	at time.Time
+}
+
+func newRegistry() *registry {
+	return &registry{
+		jobs:      make(map[string]*entry),
+		retention: DefaultHistoryRetention,
+		finished:  list.New(),
+		keys:      make(map[string]claim),
+	}
+}
+
+// check returns ErrInvalidTransition if the job with the given ID may not
+// move to next. Jobs the registry does not know may start in any state.
+func (r *registry) check(id string, next utils.JobState) error {
+	if e, ok := r.jobs[id]; ok && !e.state.CanBecome(next) {
+		return fmt.Errorf("%w: job %s is %s, not %s", ErrInvalidTransition, id, e.state, next)
+	}
+	return nil
+}
+
+// set moves job to state, recording the transition.
+func (r *registry) set(job utils.Job, state utils.JobState) {
+	now := time.Now()
+	r.prune(now)
+	r.sweep(now)
+
+	e, ok := r.jobs[job.ID]
+	if !ok {
+		e = &entry{}
+		r.jobs[job.ID] = e
+	}
+	e.uniqueKey = job.UniqueKey
+	e.state = state
+	e.history = append(e.history, Transition{State: state, At: now})
+	if state == utils.Succeeded || state == utils.Delivered || state == utils.Dead {
+		r.finished.PushBack(finishedJob{id: job.ID, at: now})
+	} else {
+		r.claim(job, now)
+	}
+}
+
+func (r *registry) forget(id string) {
+	r.release(id)
+	delete(r.jobs, id)
+}
+
+// prune forgets jobs that finished more than retention ago and have not
+// moved on since.
+func (r *registry) prune(now time.Time) {
+	for e := r.finished.Front(); e != nil; e = r.finished.Front() {
+		f := e.Value.(finishedJob)
+		if now.Sub(f.at) < r.retention {
+			return
+		}
+		r.finished.Remove(e)
+		e, ok := r.jobs[f.id]
+		if ok && len(e.history) > 0 && !e.history[len(e.history)-1].At.After(f.at) {
+			delete(r.jobs, f.id)
+		}
+	}
+}
+
+func (r *registry) get(id string) (JobInfo, bool) {
+	r.prune(time.Now())
+	e, ok := r.jobs[id]
+	if !ok {
+		return JobInfo{}, false
+	}
+	return JobInfo{Job: utils.Job{ID: id}, State: e.state, History: append([]Transition(nil), e.history...)}, true
+}
+
+// GetJobByID returns what the queue knows about the job with the given ID,
+// or ErrUnknownJob. The returned Job is the scheduled, queued, in-flight or
+// dead-lettered copy; once the job has succeeded or been delivered only its
+// ID is left.
+func (q *JobQueue) GetJobByID(id string) (JobInfo, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.requeueExpired()
+	info, ok := q.jobs.get(id)
+	if !ok {
+		return JobInfo{}, fmt.Errorf("%w %s", ErrUnknownJob, id)
+	}
+	if sj, ok, err := q.storage.ScheduledJob(id); err != nil {
+		return JobInfo{}, err
+	} else if ok {
+		info.Job = sj.Job
+		return info, nil
+	}
+	job, ok, err := q.storage.Job(id)
+	if err != nil {
+		return JobInfo{}, err
+	}
+	if ok {
+		info.Job = job
+	}
+	return info, nil
+}
+
+// seed records the state of every job in the storage and every scheduled
+// job. It is called by NewQueue.
+func (q *JobQueue) seed() error {
+	names, err := q.storage.Queues()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		ps, err := q.storage.Priorities(name)
+		if err != nil {
+			return err
+		}
+		for _, p := range ps {
+			jobs, err := q.storage.List(name, p)
+			if err != nil {
+				return err
+			}
+			for _, job := range jobs {
+				q.jobs.set(job, utils.Queued)
+			}
+		}
+
+		ps, err = q.storage.DeadLetterPriorities(name)
+		if err != nil {
+			return err
+		}
+		for _, p := range ps {
+			jobs, err := q.storage.ListDeadLetter(name, p)
+			if err != nil {
+				return err
+			}
+			for _, job := range jobs {
+				q.jobs.set(job, utils.Dead)
+			}
+		}
+	}
+
+	held, err := q.storage.ListInFlight()
+	if err != nil {
+		return err
+	}
+	for _, h := range held {
+		// Whether the job was leased or waiting for a retry is not
+		// stored; either way it is back once Until has passed.
+		q.jobs.set(h.Job, utils.Running)
+	}
+	scheduled, err := q.storage.ScheduledJobs()
+	if err != nil {
+		return err
+	}
+	for _, sj := range scheduled {
+		q.jobs.set(sj.Job, utils.Scheduled)
+	}
+	return nil
+}
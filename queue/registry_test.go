@@ -0,0 +1,161 @@
+package queue
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Avik-creator/utils"
+)
+
+func states(info JobInfo) []utils.JobState {
+	var got []utils.JobState
+	for _, tr := range info.History {
+		got = append(got, tr.State)
+	}
+	return got
+}
+
+func TestGetJobByIDFollowsLifecycle(t *testing.T) {
+	q := NewQueue()
+	q.AddJob(utils.Job{ID: "job1", Priority: utils.High, CreatedAt: time.Now()})
+
+	l, err := q.Lease(time.Minute)
+	if err != nil {
+		t.Fatalf("Lease failed: %v", err)
+	}
+	if info, _ := q.GetJobByID("job1"); info.State != utils.Running {
+		t.Errorf("Expected job1 to be running, got %s", info.State)
+	}
+
+	q.Nack(l, 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	if info, _ := q.GetJobByID("job1"); info.State != utils.Queued {
+		t.Errorf("Expected job1 to be queued again after its retry delay, got %s", info.State)
+	}
+
+	l, _ = q.Lease(time.Minute)
+	q.Ack(l)
+
+	info, err := q.GetJobByID("job1")
+	if err != nil {
+		t.Fatalf("GetJobByID failed: %v", err)
+	}
+	want := []utils.JobState{utils.Queued, utils.Running, utils.Retrying, utils.Queued, utils.Running, utils.Succeeded}
+	if got := states(info); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected history %v, got %v", want, got)
+	}
+	for i := 1; i < len(info.History); i++ {
+		if info.History[i].At.Before(info.History[i-1].At) {
+			t.Errorf("Expected transition times in order, got %v", info.History)
+		}
+	}
+}
+
+func TestGetJobByIDReadsJobFromStorage(t *testing.T) {
+	q := NewQueue()
+	q.AddJob(utils.Job{ID: "job1", Priority: utils.High, CreatedAt: time.Now(), Payload: map[string]string{"to": "a@example.com"}})
+
+	info, err := q.GetJobByID("job1")
+	if err != nil || info.Job.Payload["to"] != "a@example.com" {
+		t.Fatalf("Expected the queued copy of job1, got %+v (%v)", info.Job, err)
+	}
+	if e := q.jobs.jobs["job1"]; e.state != utils.Queued {
+		t.Errorf("Expected the registry to track job1 as queued, got %+v", e)
+	}
+
+	l, _ := q.Lease(time.Minute)
+	if info, _ := q.GetJobByID("job1"); info.Job.Payload["to"] != "a@example.com" {
+		t.Errorf("Expected the in-flight copy of job1, got %+v", info.Job)
+	}
+	q.Ack(l)
+	if info, _ := q.GetJobByID("job1"); info.Job.ID != "job1" || info.Job.Payload != nil {
+		t.Errorf("Expected only the ID of a succeeded job, got %+v", info.Job)
+	}
+}
+
+func TestInvalidTransitions(t *testing.T) {
+	q := NewQueue()
+	job := utils.Job{ID: "job1", Priority: utils.High, CreatedAt: time.Now()}
+	q.AddJob(job)
+
+	// A queued job cannot be retrying; it stays in its bucket.
+	q.RetryJob(job, time.Minute)
+	if info, _ := q.GetJobByID("job1"); info.State != utils.Queued {
+		t.Errorf("Expected job1 to stay queued, got %s", info.State)
+	}
+
+	l, _ := q.Lease(time.Minute)
+	q.Ack(l)
+	if err := q.AddJob(job); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Expected ErrInvalidTransition adding a succeeded job, got %v", err)
+	}
+	if err := q.Schedule(job, time.Now()); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Expected ErrInvalidTransition scheduling a succeeded job, got %v", err)
+	}
+}
+
+func TestDeadJobCanBeQueuedAgain(t *testing.T) {
+	q := NewQueue()
+	job := utils.Job{ID: "job1", Priority: utils.High, CreatedAt: time.Now()}
+	q.AddJob(job)
+	q.MoveJobToDeadLetterQueue(job)
+
+	if info, _ := q.GetJobByID("job1"); info.State != utils.Dead {
+		t.Errorf("Expected job1 to be dead, got %s", info.State)
+	}
+	if err := q.AddJob(job); err != nil {
+		t.Errorf("Expected a dead job to be queued again, got %v", err)
+	}
+}
+
+func TestGetJobByIDUnknown(t *testing.T) {
+	q := NewQueue(WithHistoryRetention(10 * time.Millisecond))
+	if _, err := q.GetJobByID("missing"); !errors.Is(err, ErrUnknownJob) {
+		t.Errorf("Expected ErrUnknownJob, got %v", err)
+	}
+
+	job := utils.Job{ID: "removed", Priority: utils.High, CreatedAt: time.Now()}
+	q.AddJob(job)
+	q.RemoveJobFromQueue(job)
+	if _, err := q.GetJobByID("removed"); !errors.Is(err, ErrUnknownJob) {
+		t.Errorf("Expected a removed job to be forgotten, got %v", err)
+	}
+
+	q.AddJob(utils.Job{ID: "done", Priority: utils.High, CreatedAt: time.Now()})
+	l, _ := q.Lease(time.Minute)
+	q.Ack(l)
+	time.Sleep(20 * time.Millisecond)
+	if _, err := q.GetJobByID("done"); !errors.Is(err, ErrUnknownJob) {
+		t.Errorf("Expected a succeeded job to be forgotten after the retention, got %v", err)
+	}
+
+	q.AddJob(utils.Job{ID: "taken", Priority: utils.High, CreatedAt: time.Now()})
+	q.GetJob()
+	time.Sleep(20 * time.Millisecond)
+	if _, err := q.GetJobByID("taken"); !errors.Is(err, ErrUnknownJob) {
+		t.Errorf("Expected a job taken without a lease to be forgotten after the retention, got %v", err)
+	}
+}
+
+func TestJobStatesSurviveRestart(t *testing.T) {
+	dir := t.TempDir()
+
+	q := NewQueue(WithLog(openTestLog(t, dir)))
+	q.AddJob(utils.Job{ID: "queued", Priority: utils.Low, CreatedAt: time.Now()})
+	q.AddJob(utils.Job{ID: "dead", Priority: utils.Low, CreatedAt: time.Now()})
+	q.MoveJobToDeadLetterQueue(utils.Job{ID: "dead", Priority: utils.Low})
+	q.Schedule(utils.Job{ID: "later", Priority: utils.Low}, time.Now().Add(time.Hour))
+	q.Close()
+
+	q = NewQueue(WithLog(openTestLog(t, dir)))
+	defer q.Close()
+
+	for id, want := range map[string]utils.JobState{"queued": utils.Queued, "dead": utils.Dead, "later": utils.Scheduled} {
+		info, err := q.GetJobByID(id)
+		if err != nil || info.State != want {
+			t.Errorf("Expected %s to be %s after a restart, got %s (%v)", id, want, info.State, err)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// DefaultResultRetention is how long a job's result is kept after it
+// succeeded.
+const DefaultResultRetention = 24 * time.Hour
+
+// resultPruneInterval is how often AckResult sweeps out expired results.
+const resultPruneInterval = time.Minute
+
+// ErrNoResult is returned by GetResult for a job without a stored result,
+// including one whose result has expired.
+var ErrNoResult = errors.New("no result")
+
+// Result is the value a job's handler returned, kept until ExpiresAt.
+type Result struct {
+	JobID     string    `json:"job_id"`
+	Data      []byte    `json:"data"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// WithResultRetention sets how long results are kept. The default is
+// DefaultResultRetention.
+func WithResultRetention(d time.Duration) Option {
+	return func(q *JobQueue) {
+		q.resultRetention = d
+	}
+}
+
+// AckResult is Ack that also stores data, the job's result, under the job's
+// ID. A nil data stores nothing.
+func (q *JobQueue) AckResult(l *Lease, data []byte) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if err := q.checkLease(l); err != nil {
+		return err
+	}
+	if data != nil {
+		now := time.Now()
+		r := Result{JobID: l.Job.ID, Data: data, CreatedAt: now, ExpiresAt: now.Add(q.resultRetention)}
+		if q.wal != nil {
+			if err := q.wal.append(record{Op: opResult, Job: l.Job, Result: &r}); err != nil {
+				return err
+			}
+		}
+		if err := q.storage.SaveResult(r); err != nil {
+			return err
+		}
+		q.pruneResults(now)
+	}
+	return q.ack(l)
+}
+
+// GetResult returns the stored result of the job with the given ID, or
+// ErrNoResult.
+func (q *JobQueue) GetResult(id string) (Result, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	r, ok, err := q.storage.Result(id)
+	if err != nil {
+		return Result{}, err
+	}
+	if !ok || !r.ExpiresAt.After(time.Now()) {
+		return Result{}, fmt.Errorf("%w for job %s", ErrNoResult, id)
+	}
+	return r, nil
+}
+
+// pruneResults deletes expired results, at most once per
+// resultPruneInterval. It is called with q.mu held.
+func (q *JobQueue) pruneResults(now time.Time) {
+	if now.Sub(q.lastPrune) < resultPruneInterval {
+		return
+	}
+	q.lastPrune = now
+	if err := q.storage.PruneResults(now); err != nil {
+		log.Printf("queue: failed to prune results: %v", err)
+	}
+}
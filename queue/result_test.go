@@ -0,0 +1,67 @@
+package queue
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Avik-creator/utils"
+)
+
+func TestAckResult(t *testing.T) {
+	q := NewQueue(WithResultRetention(30 * time.Millisecond))
+	q.AddJob(utils.Job{ID: "job1", Priority: utils.High, CreatedAt: time.Now()})
+	q.AddJob(utils.Job{ID: "job2", Priority: utils.High, CreatedAt: time.Now()})
+
+	l, _ := q.Lease(time.Minute)
+	if err := q.AckResult(l, []byte(`{"ok":true}`)); err != nil {
+		t.Fatalf("AckResult failed: %v", err)
+	}
+	r, err := q.GetResult("job1")
+	if err != nil {
+		t.Fatalf("GetResult failed: %v", err)
+	}
+	if string(r.Data) != `{"ok":true}` || r.JobID != "job1" {
+		t.Errorf("Unexpected result %+v", r)
+	}
+	if info, _ := q.GetJobByID("job1"); info.State != utils.Succeeded {
+		t.Errorf("Expected AckResult to ack the job, got %s", info.State)
+	}
+
+	l, _ = q.Lease(time.Minute)
+	q.Ack(l)
+	if _, err := q.GetResult("job2"); !errors.Is(err, ErrNoResult) {
+		t.Errorf("Expected ErrNoResult for a job acked without a result, got %v", err)
+	}
+
+	time.Sleep(40 * time.Millisecond)
+	if _, err := q.GetResult("job1"); !errors.Is(err, ErrNoResult) {
+		t.Errorf("Expected the result to expire, got %v", err)
+	}
+}
+
+func TestResultSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+
+	q := NewQueue(WithLog(openTestLog(t, dir)))
+	q.AddJob(utils.Job{ID: "job1", Priority: utils.High, CreatedAt: time.Now()})
+	q.AddJob(utils.Job{ID: "job2", Priority: utils.High, CreatedAt: time.Now()})
+	l, _ := q.Lease(time.Minute)
+	q.AckResult(l, []byte("one"))
+	if err := q.Snapshot(); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	l, _ = q.Lease(time.Minute)
+	q.AckResult(l, []byte("two"))
+	q.Close()
+
+	q = NewQueue(WithLog(openTestLog(t, dir)))
+	defer q.Close()
+
+	for id, want := range map[string]string{"job1": "one", "job2": "two"} {
+		r, err := q.GetResult(id)
+		if err != nil || string(r.Data) != want {
+			t.Errorf("Expected result %q for %s after a restart, got %q (%v)", want, id, r.Data, err)
+		}
+	}
+}
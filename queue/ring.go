@@ -0,0 +1,205 @@
+package queue
+
+import (
+	"sort"
+
+	"github.com/Avik-creator/utils"
+)
+
+const minRing = 8
+
+// ring is one priority bucket: a growable ring buffer of jobs in dequeue
+// order, with an index from job ID to position so that any job can be taken
+// out in constant time.
+//
+// Positions are absolute and only map onto slots modulo the buffer size, so
+// jobs can be added at either end without moving the rest. A removed job
+// leaves a hole that keeps its CreatedAt, so the buffer stays sorted; holes
+// are trimmed from both ends straight away and the buffer is compacted once
+// they outnumber the jobs.
+type ring struct {
+	slots      []slot
+	head, tail int64
+	live       int
+	pos        map[string]int64
+}
+
+type slot struct {
+	job utils.Job
+	ok  bool
+}
+
+func newRing() *ring {
+	return &ring{slots: make([]slot, minRing), pos: make(map[string]int64)}
+}
+
+func (r *ring) at(pos int64) *slot {
+	return &r.slots[pos&int64(len(r.slots)-1)]
+}
+
+// len returns the number of jobs in r. A nil ring is empty.
+func (r *ring) len() int {
+	if r == nil {
+		return 0
+	}
+	return r.live
+}
+
+// push adds job after every job created no later than it. Jobs arriving in
+// CreatedAt order, and jobs older than every job in r such as an expired
+// lease coming back, are added in constant time; anything else has to shift
+// the jobs between its place and the nearest hole or end of r.
+func (r *ring) push(job utils.Job) {
+	if r.tail-r.head == int64(len(r.slots)) {
+		r.resize(2 * r.live)
+	}
+
+	switch {
+	case r.live == 0 || !r.at(r.tail-1).job.CreatedAt.After(job.CreatedAt):
+		r.put(r.tail, job)
+		r.tail++
+	case r.at(r.head).job.CreatedAt.After(job.CreatedAt):
+		r.head--
+		r.put(r.head, job)
+	default:
+		n := int(r.tail - r.head)
+		i := r.head + int64(sort.Search(n, func(i int) bool {
+			return r.at(r.head + int64(i)).job.CreatedAt.After(job.CreatedAt)
+		}))
+		// Make room by shifting the jobs between i and the nearest hole,
+		// or the nearer end, by one slot.
+		for lo, hi := i-1, i; ; lo, hi = lo-1, hi+1 {
+			if hi == r.tail || !r.at(hi).ok {
+				for p := hi; p > i; p-- {
+					r.move(p-1, p)
+				}
+				if hi == r.tail {
+					r.tail++
+				}
+				r.put(i, job)
+				break
+			}
+			if lo < r.head || !r.at(lo).ok {
+				for p := lo; p < i-1; p++ {
+					r.move(p+1, p)
+				}
+				if lo < r.head {
+					r.head--
+				}
+				r.put(i-1, job)
+				break
+			}
+		}
+	}
+	r.live++
+}
+
+// move copies the slot at from to the slot at to.
+func (r *ring) move(from, to int64) {
+	s := *r.at(from)
+	*r.at(to) = s
+	if s.ok {
+		r.pos[s.job.ID] = to
+	}
+}
+
+func (r *ring) put(pos int64, job utils.Job) {
+	*r.at(pos) = slot{job: job, ok: true}
+	r.pos[job.ID] = pos
+}
+
+// job returns the job with the given ID.
+func (r *ring) job(id string) (utils.Job, bool) {
+	pos, ok := r.pos[id]
+	if !ok {
+		return utils.Job{}, false
+	}
+	return r.at(pos).job, true
+}
+
+// peek returns the job at the head of r.
+func (r *ring) peek() (utils.Job, bool) {
+	if r.live == 0 {
+		return utils.Job{}, false
+	}
+	return r.at(r.head).job, true
+}
+
+// first returns the first job of r, in dequeue order, that match accepts.
+func (r *ring) first(match func(utils.Job) bool) (utils.Job, bool) {
+	for p := r.head; p < r.tail; p++ {
+		if s := r.at(p); s.ok && match(s.job) {
+			return s.job, true
+		}
+	}
+	return utils.Job{}, false
+}
+
+// pop takes the job at the head of r.
+func (r *ring) pop() (utils.Job, bool) {
+	job, ok := r.peek()
+	if ok {
+		r.remove(job.ID)
+	}
+	return job, ok
+}
+
+// remove takes the job with the given ID out of r, reporting whether it was
+// there.
+func (r *ring) remove(id string) bool {
+	pos, ok := r.pos[id]
+	if !ok {
+		return false
+	}
+	delete(r.pos, id)
+	s := r.at(pos)
+	// Keep CreatedAt so that push can still binary search past the hole.
+	*s = slot{job: utils.Job{CreatedAt: s.job.CreatedAt}}
+	r.live--
+
+	for r.head < r.tail && !r.at(r.head).ok {
+		*r.at(r.head) = slot{}
+		r.head++
+	}
+	for r.tail > r.head && !r.at(r.tail-1).ok {
+		*r.at(r.tail - 1) = slot{}
+		r.tail--
+	}
+	if holes := int(r.tail-r.head) - r.live; holes > r.live || (len(r.slots) > minRing && r.live < len(r.slots)/4) {
+		r.resize(2 * r.live)
+	}
+	return true
+}
+
+// resize moves the jobs of r, without holes, into a buffer with room for at
+// least n jobs.
+func (r *ring) resize(n int) {
+	size := minRing
+	for size < n {
+		size *= 2
+	}
+	slots := make([]slot, size)
+	var i int64
+	for p := r.head; p < r.tail; p++ {
+		if s := r.at(p); s.ok {
+			slots[i] = *s
+			r.pos[s.job.ID] = i
+			i++
+		}
+	}
+	r.slots, r.head, r.tail = slots, 0, i
+}
+
+// jobs returns the jobs in r in dequeue order.
+func (r *ring) jobs() []utils.Job {
+	if r == nil {
+		return nil
+	}
+	jobs := make([]utils.Job, 0, r.live)
+	for p := r.head; p < r.tail; p++ {
+		if s := r.at(p); s.ok {
+			jobs = append(jobs, s.job)
+		}
+	}
+	return jobs
+}
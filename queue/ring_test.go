@@ -0,0 +1,241 @@
+package queue
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Avik-creator/utils"
+)
+
+func ringIDs(r *ring) []string {
+	var got []string
+	for _, job := range r.jobs() {
+		got = append(got, job.ID)
+	}
+	return got
+}
+
+func TestRingKeepsCreatedAtOrder(t *testing.T) {
+	r := newRing()
+	base := time.Now()
+	at := func(id string, s int) utils.Job {
+		return utils.Job{ID: id, CreatedAt: base.Add(time.Duration(s) * time.Second)}
+	}
+
+	r.push(at("b", 2))
+	r.push(at("d", 4))
+	r.push(at("a", 1))  // older than everything: goes on the head
+	r.push(at("c", 3))  // in between: shifts d along
+	r.push(at("d2", 4)) // same CreatedAt: after d
+
+	if got := ringIDs(r); !equal(got, []string{"a", "b", "c", "d", "d2"}) {
+		t.Errorf("Expected jobs in CreatedAt order, got %v", got)
+	}
+
+	r.remove("c")
+	r.push(at("c2", 3))
+	if got := ringIDs(r); !equal(got, []string{"a", "b", "c2", "d", "d2"}) {
+		t.Errorf("Expected a job to take the place of a removed one, got %v", got)
+	}
+}
+
+func TestRingRemove(t *testing.T) {
+	r := newRing()
+	now := time.Now()
+	for i := 0; i < 100; i++ {
+		r.push(utils.Job{ID: fmt.Sprint(i), CreatedAt: now})
+	}
+
+	for i := 1; i < 100; i += 2 {
+		if !r.remove(fmt.Sprint(i)) {
+			t.Fatalf("Expected job %d to be removed", i)
+		}
+	}
+	if r.remove("1") {
+		t.Error("Expected removing a job twice to report false")
+	}
+	if r.len() != 50 {
+		t.Fatalf("Expected 50 jobs, got %d", r.len())
+	}
+
+	for i := 0; i < 100; i += 2 {
+		job, ok := r.pop()
+		if !ok || job.ID != fmt.Sprint(i) {
+			t.Fatalf("Expected job %d, got %s ok=%v", i, job.ID, ok)
+		}
+	}
+	if _, ok := r.pop(); ok {
+		t.Error("Expected the ring to be empty")
+	}
+}
+
+func TestRingOutOfOrder(t *testing.T) {
+	r := newRing()
+	base := time.Now()
+	perm := rand.Perm(1000)
+	for _, i := range perm {
+		r.push(utils.Job{ID: fmt.Sprint(i), CreatedAt: base.Add(time.Duration(i))})
+	}
+	for _, i := range perm[:500] {
+		r.remove(fmt.Sprint(i))
+	}
+
+	jobs := r.jobs()
+	if len(jobs) != 500 || r.len() != 500 {
+		t.Fatalf("Expected 500 jobs, got %d (len %d)", len(jobs), r.len())
+	}
+	for i := 1; i < len(jobs); i++ {
+		if !jobs[i-1].CreatedAt.Before(jobs[i].CreatedAt) {
+			t.Fatalf("Expected jobs in CreatedAt order, got %s before %s", jobs[i-1].ID, jobs[i].ID)
+		}
+	}
+	for _, want := range jobs {
+		if job, ok := r.pop(); !ok || job.ID != want.ID {
+			t.Fatalf("Expected job %s, got %s ok=%v", want.ID, job.ID, ok)
+		}
+	}
+	if len(r.slots) != minRing {
+		t.Errorf("Expected an empty ring to shrink back, got %d slots", len(r.slots))
+	}
+}
+
+func TestRingMixedOperations(t *testing.T) {
+	r := newRing()
+	base := time.Now()
+	var want []utils.Job
+	for i := range 5000 {
+		switch op := rand.IntN(4); {
+		case op < 2:
+			job := utils.Job{ID: fmt.Sprint(i), CreatedAt: base.Add(time.Duration(rand.IntN(200)))}
+			r.push(job)
+			at := len(want)
+			for at > 0 && want[at-1].CreatedAt.After(job.CreatedAt) {
+				at--
+			}
+			want = slices.Insert(want, at, job)
+		case op == 2 && len(want) > 0:
+			k := rand.IntN(len(want))
+			r.remove(want[k].ID)
+			want = slices.Delete(want, k, k+1)
+		case len(want) > 0:
+			if job, ok := r.pop(); !ok || job.ID != want[0].ID {
+				t.Fatalf("Expected job %s, got %s ok=%v", want[0].ID, job.ID, ok)
+			}
+			want = want[1:]
+		}
+	}
+
+	var ids []string
+	for _, job := range want {
+		ids = append(ids, job.ID)
+	}
+	if got := ringIDs(r); !equal(got, ids) || r.len() != len(want) {
+		t.Errorf("Expected %v, got %v", ids, got)
+	}
+}
+
+func TestRingShrinks(t *testing.T) {
+	r := newRing()
+	now := time.Now()
+	for i := 0; i < 1024; i++ {
+		r.push(utils.Job{ID: fmt.Sprint(i), CreatedAt: now})
+	}
+	for i := 0; i < 1020; i++ {
+		r.pop()
+	}
+	if len(r.slots) > 64 {
+		t.Errorf("Expected the buffer to shrink as jobs are taken, still %d slots", len(r.slots))
+	}
+	if got := ringIDs(r); !equal(got, []string{"1020", "1021", "1022", "1023"}) {
+		t.Errorf("Expected the remaining jobs to survive shrinking, got %v", got)
+	}
+}
+
+func TestMemoryStorageReplacesJobWithSameID(t *testing.T) {
+	m := NewMemoryStorage()
+	m.Enqueue(utils.Job{ID: "job1", Queue: utils.DefaultQueue, Priority: utils.Low, CreatedAt: time.Now()})
+	m.Enqueue(utils.Job{ID: "job1", Queue: utils.DefaultQueue, Priority: utils.High, CreatedAt: time.Now()})
+
+	if n, _ := m.Len(utils.DefaultQueue, utils.Low); n != 0 {
+		t.Errorf("Expected the Low copy to be replaced, got %d Low jobs", n)
+	}
+	if n, _ := m.Len(utils.DefaultQueue, utils.High); n != 1 {
+		t.Errorf("Expected 1 High job, got %d", n)
+	}
+}
+
+// filled returns a MemoryStorage holding n queued jobs at the same priority.
+func filled(b *testing.B, n int) *MemoryStorage {
+	b.Helper()
+	m := NewMemoryStorage()
+	now := time.Now()
+	for i := 0; i < n; i++ {
+		m.Enqueue(utils.Job{ID: fmt.Sprint(i), Queue: utils.DefaultQueue, Priority: utils.Medium, CreatedAt: now.Add(time.Duration(i))})
+	}
+	return m
+}
+
+var benchSizes = []int{1_000, 1_000_000}
+
+func BenchmarkMemoryEnqueue(b *testing.B) {
+	for _, n := range benchSizes {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			m := filled(b, n)
+			now := time.Now().Add(time.Hour)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				m.Enqueue(utils.Job{ID: fmt.Sprint("new-", i), Queue: utils.DefaultQueue, Priority: utils.Medium, CreatedAt: now})
+			}
+		})
+	}
+}
+
+func BenchmarkMemoryDequeue(b *testing.B) {
+	for _, n := range benchSizes {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			m := filled(b, n)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				// Put the job back so the queue stays at n jobs.
+				job, _, _ := m.Dequeue(utils.DefaultQueue, utils.Medium, time.Time{})
+				job.CreatedAt = job.CreatedAt.Add(time.Hour)
+				m.Enqueue(job)
+			}
+		})
+	}
+}
+
+func BenchmarkMemoryEnqueueOutOfOrder(b *testing.B) {
+	for _, n := range benchSizes {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			m := filled(b, n)
+			oldest, _, _ := m.Peek(utils.DefaultQueue, utils.Medium)
+			now := oldest.CreatedAt
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				// Slot a job in among the queued ones and take it out again.
+				job := utils.Job{ID: "new", Queue: utils.DefaultQueue, Priority: utils.Medium, CreatedAt: now.Add(time.Duration(rand.IntN(n)))}
+				m.Enqueue(job)
+				m.Remove(job)
+			}
+		})
+	}
+}
+
+func BenchmarkMemoryRemove(b *testing.B) {
+	for _, n := range benchSizes {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			m := filled(b, n)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				// Take a job out of the middle and put it back at the end.
+				job := utils.Job{ID: fmt.Sprint(n / 2), Queue: utils.DefaultQueue, Priority: utils.Medium, CreatedAt: time.Now().Add(time.Hour)}
+				m.Remove(job)
+				m.Enqueue(job)
+			}
+		})
+	}
+}
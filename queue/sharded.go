@@ -0,0 +1,178 @@
+package queue
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Avik-creator/utils"
+)
+
+// ShardedQueue is an in-memory queue for many concurrent producers and
+// consumers. Jobs are spread over shards by ID, each with its own lock, and
+// every shard publishes its most urgent waiting priority per named queue
+// without locking, so a consumer only locks the one shard it takes a job
+// from.
+//
+// Priorities are served strictly: GetJob returns a job of the most urgent
+// priority waiting in any shard when it looks. Within a priority jobs leave
+// each shard in CreatedAt order, but shards take turns, so order across
+// shards is only approximate. ShardedQueue has none of JobQueue's leases,
+// logging, strategies, aging or capacity limits.
+type ShardedQueue struct {
+	shards []*shard
+	turn   atomic.Uint64
+}
+
+type shard struct {
+	mu      sync.Mutex
+	storage *MemoryStorage
+	// tops maps a queue name to an *atomic.Int64 holding the most urgent
+	// priority with jobs waiting in this shard, or 0 when there are none.
+	tops sync.Map
+}
+
+// NewShardedQueue returns a ShardedQueue with n shards, or one per CPU when n
+// is not positive.
+func NewShardedQueue(n int) *ShardedQueue {
+	if n <= 0 {
+		n = runtime.GOMAXPROCS(0)
+	}
+	q := &ShardedQueue{shards: make([]*shard, n)}
+	for i := range q.shards {
+		q.shards[i] = &shard{storage: NewMemoryStorage()}
+	}
+	return q
+}
+
+// AddJob adds job to the shard its ID belongs to, replacing any job queued
+// there under the same ID. It fails with ErrMissingID for a job without an
+// ID and with ErrInvalidPriority for a job with an invalid priority.
+func (q *ShardedQueue) AddJob(job utils.Job) error {
+	job = withQueue(job)
+	if err := checkID(job); err != nil {
+		return err
+	}
+	if err := checkPriority(job); err != nil {
+		return err
+	}
+
+	s := q.shards[shardOf(job.ID, len(q.shards))]
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.storage.Enqueue(job); err != nil {
+		return err
+	}
+	s.publish(job.Queue)
+	return nil
+}
+
+// GetJob takes the next job by priority from the named queues, or from the
+// default queue when none are given.
+func (q *ShardedQueue) GetJob(queues ...string) (utils.Job, error) {
+	if len(queues) == 0 {
+		queues = []string{utils.DefaultQueue}
+	}
+
+	for {
+		s, name := q.most(queues)
+		if s == nil {
+			return utils.Job{}, ErrEmpty
+		}
+		if job, ok := s.take(name); ok {
+			return job, nil
+		}
+		// Another consumer emptied the shard after we looked; look again.
+	}
+}
+
+// most returns the shard and queue holding the most urgent waiting job, or a
+// nil shard when every shard is empty. The scan starts at a different shard
+// each call so that consumers spread out over shards holding jobs of the
+// same priority.
+func (q *ShardedQueue) most(queues []string) (*shard, string) {
+	var best *shard
+	var bestQueue string
+	var bestPriority int64
+
+	n := uint64(len(q.shards))
+	start := q.turn.Add(1)
+	for i := uint64(0); i < n; i++ {
+		s := q.shards[(start+i)%n]
+		for _, name := range queues {
+			p := s.top(name)
+			if p != 0 && (best == nil || p < bestPriority) {
+				best, bestQueue, bestPriority = s, name, p
+			}
+		}
+	}
+	return best, bestQueue
+}
+
+// take dequeues the most urgent job of the named queue in s.
+func (s *shard) take(name string) (utils.Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	mq, ok := s.storage.queue[name]
+	if !ok || len(mq.levels) == 0 {
+		return utils.Job{}, false
+	}
+	job, ok := mq.pop(mq.levels[0])
+	s.publish(name)
+	return job, ok
+}
+
+// Len returns the number of jobs waiting in the named queues, or in the
+// default queue when none are given.
+func (q *ShardedQueue) Len(queues ...string) int {
+	if len(queues) == 0 {
+		queues = []string{utils.DefaultQueue}
+	}
+
+	n := 0
+	for _, s := range q.shards {
+		s.mu.Lock()
+		for _, name := range queues {
+			if mq, ok := s.storage.queue[name]; ok {
+				for _, b := range mq.buckets {
+					n += b.len()
+				}
+			}
+		}
+		s.mu.Unlock()
+	}
+	return n
+}
+
+func (s *shard) top(name string) int64 {
+	if v, ok := s.tops.Load(name); ok {
+		return v.(*atomic.Int64).Load()
+	}
+	return 0
+}
+
+// publish updates the most urgent priority of the named queue in s. It is
+// called with s.mu held.
+func (s *shard) publish(name string) {
+	var p int64
+	if mq, ok := s.storage.queue[name]; ok && len(mq.levels) > 0 {
+		p = int64(mq.levels[0])
+	}
+	v, ok := s.tops.Load(name)
+	if !ok {
+		v, _ = s.tops.LoadOrStore(name, new(atomic.Int64))
+	}
+	v.(*atomic.Int64).Store(p)
+}
+
+// shardOf hashes id with FNV-1a to pick one of n shards.
+func shardOf(id string, n int) int {
+	h := uint32(2166136261)
+	for i := 0; i < len(id); i++ {
+		h ^= uint32(id[i])
+		h *= 16777619
+	}
+	return int(h % uint32(n))
+}
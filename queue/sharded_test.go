@@ -0,0 +1,135 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Avik-creator/utils"
+)
+
+func TestShardedQueuePriorityOrder(t *testing.T) {
+	q := NewShardedQueue(8)
+	now := time.Now()
+	for i := 0; i < 30; i++ {
+		p := []utils.Priority{utils.Low, utils.Medium, utils.High}[i%3]
+		if err := q.AddJob(utils.Job{ID: fmt.Sprint(i), Priority: p, CreatedAt: now}); err != nil {
+			t.Fatalf("AddJob failed: %v", err)
+		}
+	}
+	if n := q.Len(); n != 30 {
+		t.Fatalf("Expected 30 jobs, got %d", n)
+	}
+
+	last := utils.MinPriority
+	for i := 0; i < 30; i++ {
+		job, err := q.GetJob()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if job.Priority < last {
+			t.Fatalf("Got priority %d after %d", job.Priority, last)
+		}
+		last = job.Priority
+	}
+	if _, err := q.GetJob(); !errors.Is(err, ErrEmpty) {
+		t.Errorf("Expected ErrEmpty, got %v", err)
+	}
+}
+
+func TestShardedQueueNamedQueues(t *testing.T) {
+	q := NewShardedQueue(4)
+	q.AddJob(utils.Job{ID: "email", Queue: "emails", Priority: utils.Low, CreatedAt: time.Now()})
+	q.AddJob(utils.Job{ID: "report", Queue: "reports", Priority: utils.High, CreatedAt: time.Now()})
+
+	if _, err := q.GetJob(); !errors.Is(err, ErrEmpty) {
+		t.Errorf("Expected the default queue to be empty, got %v", err)
+	}
+	job, err := q.GetJob("emails")
+	if err != nil || job.ID != "email" {
+		t.Errorf("Expected email from emails, got %s (%v)", job.ID, err)
+	}
+	if err := q.AddJob(utils.Job{ID: "bad"}); !errors.Is(err, ErrInvalidPriority) {
+		t.Errorf("Expected ErrInvalidPriority, got %v", err)
+	}
+}
+
+func TestShardedQueueConcurrent(t *testing.T) {
+	q := NewShardedQueue(4)
+	const producers, perProducer = 8, 500
+
+	var wg sync.WaitGroup
+	for p := 0; p < producers; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < perProducer; i++ {
+				q.AddJob(utils.Job{ID: fmt.Sprintf("%d-%d", p, i), Priority: utils.Medium, CreatedAt: time.Now()})
+			}
+		}(p)
+	}
+
+	var got sync.Map
+	var taken atomic.Int64
+	for c := 0; c < 8; c++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for taken.Load() < producers*perProducer {
+				job, err := q.GetJob()
+				if err != nil {
+					continue
+				}
+				if _, dup := got.LoadOrStore(job.ID, true); dup {
+					t.Errorf("Job %s was handed out twice", job.ID)
+				}
+				taken.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := taken.Load(); n != producers*perProducer {
+		t.Errorf("Expected %d jobs, got %d", producers*perProducer, n)
+	}
+}
+
+// benchQueue is what the parallel benchmarks need from a queue.
+type benchQueue interface {
+	GetJob(queues ...string) (utils.Job, error)
+}
+
+// benchParallel has every goroutine add a job and take one, against a queue
+// that starts with 1,000 jobs spread over three priorities.
+func benchParallel(b *testing.B, q benchQueue, add func(utils.Job)) {
+	now := time.Now()
+	priorities := []utils.Priority{utils.High, utils.Medium, utils.Low}
+	for i := 0; i < 1000; i++ {
+		add(utils.Job{ID: "seed-" + strconv.Itoa(i), Priority: priorities[i%3], CreatedAt: now})
+	}
+
+	var ids atomic.Uint64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			n := ids.Add(1)
+			add(utils.Job{ID: strconv.FormatUint(n, 10), Priority: priorities[n%3], CreatedAt: now})
+			q.GetJob()
+		}
+	})
+}
+
+func BenchmarkParallel(b *testing.B) {
+	b.Run("mutex", func(b *testing.B) {
+		q := NewQueue()
+		benchParallel(b, q, func(job utils.Job) { q.AddJob(job) })
+	})
+	b.Run("sharded", func(b *testing.B) {
+		q := NewShardedQueue(0)
+		benchParallel(b, q, func(job utils.Job) { q.AddJob(job) })
+	})
+}
@@ -0,0 +1,132 @@
+package queue
+
+import (
+	"errors"
+	"log"
+	"time"
+)
+
+var errQueueClosed = errors.New("queue is closed")
+
+// Snapshot writes the current contents of the queue, the dead-letter queue,
+// the in-flight jobs and the scheduled jobs to the log directory and deletes
+// the log segments the snapshot makes redundant. It is a no-op for a queue
+// without a log.
+func (q *JobQueue) Snapshot() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.wal == nil {
+		return nil
+	}
+	select {
+	case <-q.stop:
+		return errQueueClosed
+	default:
+	}
+
+	var snap snapshot
+	names, err := q.storage.Queues()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		ps, err := q.storage.Priorities(name)
+		if err != nil {
+			return err
+		}
+		for _, p := range ps {
+			jobs, err := q.storage.List(name, p)
+			if err != nil {
+				return err
+			}
+			snap.Queue = append(snap.Queue, jobs...)
+		}
+
+		ps, err = q.storage.DeadLetterPriorities(name)
+		if err != nil {
+			return err
+		}
+		for _, p := range ps {
+			dead, err := q.storage.ListDeadLetter(name, p)
+			if err != nil {
+				return err
+			}
+			snap.DeadLetter = append(snap.DeadLetter, dead...)
+		}
+	}
+	held, err := q.storage.ListInFlight()
+	if err != nil {
+		return err
+	}
+	snap.InFlight = held
+	if snap.Scheduled, err = q.storage.ScheduledJobs(); err != nil {
+		return err
+	}
+	results, err := q.storage.Results()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, r := range results {
+		if r.ExpiresAt.After(now) {
+			snap.Results = append(snap.Results, r)
+		}
+	}
+	if snap.Pauses, err = q.storage.Pauses(); err != nil {
+		return err
+	}
+
+	return q.wal.compact(snap)
+}
+
+func (q *JobQueue) restore(snap snapshot) error {
+	for _, job := range snap.Queue {
+		if err := q.storage.Enqueue(withQueue(job)); err != nil {
+			return err
+		}
+	}
+	for _, job := range snap.DeadLetter {
+		if err := q.storage.MoveToDeadLetter(withQueue(job)); err != nil {
+			return err
+		}
+	}
+	for _, h := range snap.InFlight {
+		if err := q.storage.Hold(withQueue(h.Job), h.Until); err != nil {
+			return err
+		}
+	}
+	for _, sj := range snap.Scheduled {
+		sj.Job = withQueue(sj.Job)
+		if err := q.storage.Schedule(sj, nil); err != nil {
+			return err
+		}
+	}
+	for _, r := range snap.Results {
+		if err := q.storage.SaveResult(r); err != nil {
+			return err
+		}
+	}
+	for _, p := range snap.Pauses {
+		if err := q.storage.AddPause(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (q *JobQueue) snapshotLoop() {
+	ticker := time.NewTicker(q.snapshotInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-q.stop:
+			return
+		case <-ticker.C:
+			if err := q.Snapshot(); err != nil && !errors.Is(err, errQueueClosed) {
+				log.Printf("queue: snapshot failed: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,713 @@
+// Package sqlite provides a queue.Storage backed by a local SQLite file, so
+// queue state can be inspected and repaired with ordinary SQL. It uses a
+// pure-Go SQLite driver, so it needs no cgo.
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Avik-creator/queue"
+	"github.com/Avik-creator/utils"
+	_ "modernc.org/sqlite"
+)
+
+// Timestamps are stored as fixed-width UTC text so that they sort correctly
+// and stay readable from the sqlite3 shell.
+const timeLayout = "2006-01-02T15:04:05.000000000Z"
+
+// schema creates every table. A zero priority or an empty queue or type in
+// pauses matches anything, an empty expires_at means the job never expires,
+// and timeout is in nanoseconds. scheduled holds the jobs due to be enqueued
+// at run_at, with the same columns as jobs, and claims the job holding each
+// unique key and until when it holds it once it is gone (see queue.Claim).
+const schema = `
+CREATE TABLE IF NOT EXISTS jobs (
+	seq         INTEGER PRIMARY KEY AUTOINCREMENT,
+	id          TEXT    NOT NULL UNIQUE,
+	type        TEXT    NOT NULL,
+	queue       TEXT    NOT NULL,
+	payload     TEXT    NOT NULL,
+	priority    INTEGER NOT NULL,
+	retry_count INTEGER NOT NULL,
+	max_retries INTEGER NOT NULL,
+	created_at  TEXT    NOT NULL,
+	unique_key  TEXT    NOT NULL DEFAULT '',
+	expires_at  TEXT    NOT NULL DEFAULT '',
+	dead_reason TEXT    NOT NULL DEFAULT '',
+	timeout     INTEGER NOT NULL DEFAULT 0,
+	dead_letter INTEGER NOT NULL DEFAULT 0,
+	in_flight   INTEGER NOT NULL DEFAULT 0,
+	lease_until TEXT
+);
+CREATE INDEX IF NOT EXISTS jobs_dequeue ON jobs (queue, dead_letter, in_flight, priority, created_at, seq);
+CREATE INDEX IF NOT EXISTS jobs_in_flight ON jobs (in_flight, lease_until);
+
+CREATE TABLE IF NOT EXISTS results (
+	job_id     TEXT PRIMARY KEY,
+	data       BLOB NOT NULL,
+	created_at TEXT NOT NULL,
+	expires_at TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS results_expiry ON results (expires_at);
+
+CREATE TABLE IF NOT EXISTS pauses (
+	queue    TEXT    NOT NULL,
+	priority INTEGER NOT NULL,
+	type     TEXT    NOT NULL,
+	PRIMARY KEY (queue, priority, type)
+);
+
+CREATE TABLE IF NOT EXISTS scheduled (
+	id          TEXT    PRIMARY KEY,
+	type        TEXT    NOT NULL,
+	queue       TEXT    NOT NULL,
+	payload     TEXT    NOT NULL,
+	priority    INTEGER NOT NULL,
+	retry_count INTEGER NOT NULL,
+	max_retries INTEGER NOT NULL,
+	created_at  TEXT    NOT NULL,
+	unique_key  TEXT    NOT NULL DEFAULT '',
+	expires_at  TEXT    NOT NULL DEFAULT '',
+	dead_reason TEXT    NOT NULL DEFAULT '',
+	timeout     INTEGER NOT NULL DEFAULT 0,
+	run_at      TEXT    NOT NULL
+);
+CREATE INDEX IF NOT EXISTS scheduled_run_at ON scheduled (run_at);
+CREATE INDEX IF NOT EXISTS scheduled_expires_at ON scheduled (expires_at) WHERE expires_at != '';
+
+CREATE TABLE IF NOT EXISTS claims (
+	unique_key TEXT PRIMARY KEY,
+	job_id     TEXT NOT NULL,
+	expires_at TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS claims_expiry ON claims (expires_at);
+`
+
+const columns = `id, type, queue, payload, priority, retry_count, max_retries, created_at, unique_key, expires_at, dead_reason, timeout`
+
+type Storage struct {
+	db *sql.DB
+}
+
+var _ queue.Storage = (*Storage)(nil)
+
+func Open(path string) (*Storage, error) {
+	// Transactions take the write lock up front, so that two processes
+	// checking the same unique key queue up instead of both passing the
+	// check.
+	dsn := fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=synchronous(FULL)&_txlock=immediate", path)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// JobQueue serialises access anyway; a single connection keeps SQLite
+	// from returning SQLITE_BUSY to ourselves.
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create schema: %w", err)
+	}
+	return &Storage{db: db}, nil
+}
+
+func (s *Storage) Close() error {
+	return s.db.Close()
+}
+
+func (s *Storage) Enqueue(job utils.Job) error {
+	return s.EnqueueBatch([]utils.Job{job}, nil)
+}
+
+func (s *Storage) EnqueueBatch(jobs []utils.Job, claims []queue.Claim) error {
+	return s.update(func(tx *sql.Tx) error {
+		for _, job := range jobs {
+			if err := upsert(tx, job, false, nil); err != nil {
+				return err
+			}
+			if err := unschedule(tx, job.ID); err != nil {
+				return err
+			}
+		}
+		return claim(tx, claims)
+	})
+}
+
+// update runs fn in a transaction, committing it when fn succeeds.
+func (s *Storage) update(fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *Storage) Dequeue(queueName string, priority utils.Priority, leaseUntil time.Time) (utils.Job, bool, error) {
+	return s.DequeueSkipping(queueName, priority, nil, leaseUntil)
+}
+
+func (s *Storage) DequeueSkipping(queueName string, priority utils.Priority, skipTypes []string, leaseUntil time.Time) (utils.Job, bool, error) {
+	if leaseUntil.IsZero() {
+		return s.pop(queueName, priority, false, skipTypes)
+	}
+
+	skip, args := notIn("type", skipTypes)
+	row := s.db.QueryRow(`
+		UPDATE jobs SET in_flight = 1, lease_until = ?
+		WHERE seq = (
+			SELECT seq FROM jobs
+			WHERE queue = ? AND dead_letter = 0 AND in_flight = 0 AND priority = ?`+skip+`
+			ORDER BY created_at, seq
+			LIMIT 1
+		)
+		RETURNING `+columns, append([]any{formatTime(leaseUntil), queueName, int(priority)}, args...)...)
+	return scanOne(row)
+}
+
+// notIn returns an "AND column NOT IN (...)" condition excluding values, and
+// its arguments. It is empty when values is.
+func notIn(column string, values []string) (string, []any) {
+	if len(values) == 0 {
+		return "", nil
+	}
+	args := make([]any, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return " AND " + column + " NOT IN (?" + strings.Repeat(", ?", len(values)-1) + ")", args
+}
+
+func (s *Storage) Job(id string) (utils.Job, bool, error) {
+	return scanOne(s.db.QueryRow(`SELECT `+columns+` FROM jobs WHERE id = ?`, id))
+}
+
+func (s *Storage) Peek(queueName string, priority utils.Priority) (utils.Job, bool, error) {
+	row := s.db.QueryRow(`
+		SELECT `+columns+` FROM jobs
+		WHERE queue = ? AND dead_letter = 0 AND in_flight = 0 AND priority = ?
+		ORDER BY created_at, seq
+		LIMIT 1`, queueName, int(priority))
+	return scanOne(row)
+}
+
+func (s *Storage) Len(queueName string, priority utils.Priority) (int, error) {
+	var n int
+	err := s.db.QueryRow(`
+		SELECT COUNT(*) FROM jobs
+		WHERE queue = ? AND dead_letter = 0 AND in_flight = 0 AND priority = ?`, queueName, int(priority)).Scan(&n)
+	return n, err
+}
+
+func (s *Storage) Hold(job utils.Job, until time.Time) error {
+	return upsert(s.db, job, false, &until)
+}
+
+func (s *Storage) Ack(job utils.Job) error {
+	_, err := s.db.Exec(`DELETE FROM jobs WHERE id = ? AND in_flight = 1`, job.ID)
+	return err
+}
+
+func (s *Storage) ListInFlight() ([]queue.HeldJob, error) {
+	return s.listHeld(`WHERE in_flight = 1`)
+}
+
+func (s *Storage) ListExpired(now time.Time) ([]queue.HeldJob, error) {
+	return s.listHeld(`WHERE in_flight = 1 AND lease_until <= ?`, formatTime(now))
+}
+
+// listHeld returns the in-flight jobs matching where, soonest deadline
+// first.
+func (s *Storage) listHeld(where string, args ...any) ([]queue.HeldJob, error) {
+	rows, err := s.db.Query(`
+		SELECT `+columns+`, lease_until FROM jobs
+		`+where+`
+		ORDER BY lease_until, seq`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	held := make([]queue.HeldJob, 0)
+	for rows.Next() {
+		var until string
+		job, err := scanJob(rows, &until)
+		if err != nil {
+			return nil, err
+		}
+		t, err := time.Parse(timeLayout, until)
+		if err != nil {
+			return nil, fmt.Errorf("decode lease_until of job %s: %w", job.ID, err)
+		}
+		held = append(held, queue.HeldJob{Job: job, Until: t})
+	}
+	return held, rows.Err()
+}
+
+func (s *Storage) NextDeadline() (time.Time, bool, error) {
+	var until sql.NullString
+	if err := s.db.QueryRow(`SELECT MIN(lease_until) FROM jobs WHERE in_flight = 1`).Scan(&until); err != nil {
+		return time.Time{}, false, err
+	}
+	if !until.Valid {
+		return time.Time{}, false, nil
+	}
+	t, err := time.Parse(timeLayout, until.String)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("decode lease_until: %w", err)
+	}
+	return t, true, nil
+}
+
+func (s *Storage) Remove(job utils.Job) error {
+	return s.update(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM jobs WHERE id = ? AND dead_letter = 0 AND in_flight = 0`, job.ID); err != nil {
+			return err
+		}
+		return unschedule(tx, job.ID)
+	})
+}
+
+func (s *Storage) MoveToDeadLetter(job utils.Job) error {
+	return s.update(func(tx *sql.Tx) error {
+		if err := upsert(tx, job, true, nil); err != nil {
+			return err
+		}
+		return unschedule(tx, job.ID)
+	})
+}
+
+func (s *Storage) DequeueDeadLetter(queueName string, priority utils.Priority) (utils.Job, bool, error) {
+	return s.pop(queueName, priority, true, nil)
+}
+
+func (s *Storage) List(queueName string, priority utils.Priority) ([]utils.Job, error) {
+	return s.list(queueName, priority, false)
+}
+
+func (s *Storage) ListDeadLetter(queueName string, priority utils.Priority) ([]utils.Job, error) {
+	return s.list(queueName, priority, true)
+}
+
+func (s *Storage) Priorities(queueName string) ([]utils.Priority, error) {
+	return s.priorities(queueName, false)
+}
+
+func (s *Storage) DeadLetterPriorities(queueName string) ([]utils.Priority, error) {
+	return s.priorities(queueName, true)
+}
+
+func (s *Storage) Queues() ([]string, error) {
+	rows, err := s.db.Query(`SELECT DISTINCT queue FROM jobs ORDER BY queue`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := make([]string, 0)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// upsert inserts job, or updates the row already holding its ID, so that a
+// job re-added for a retry, held in flight or moved to the dead-letter queue
+// keeps one row. The job is in flight exactly when leaseUntil is non-nil.
+// execer is a *sql.DB or *sql.Tx.
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+func upsert(db execer, job utils.Job, deadLetter bool, leaseUntil *time.Time) error {
+	args, err := jobArgs(job)
+	if err != nil {
+		return err
+	}
+
+	var until sql.NullString
+	if leaseUntil != nil {
+		until = sql.NullString{String: formatTime(*leaseUntil), Valid: true}
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO jobs (`+columns+`, dead_letter, in_flight, lease_until)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			type = excluded.type,
+			queue = excluded.queue,
+			payload = excluded.payload,
+			priority = excluded.priority,
+			retry_count = excluded.retry_count,
+			max_retries = excluded.max_retries,
+			created_at = excluded.created_at,
+			unique_key = excluded.unique_key,
+			expires_at = excluded.expires_at,
+			dead_reason = excluded.dead_reason,
+			timeout = excluded.timeout,
+			dead_letter = excluded.dead_letter,
+			in_flight = excluded.in_flight,
+			lease_until = excluded.lease_until`,
+		append(args, deadLetter, until.Valid, until)...)
+	return err
+}
+
+// jobArgs returns the values of job's columns, in the order of columns.
+func jobArgs(job utils.Job) ([]any, error) {
+	if !job.Priority.Valid() {
+		return nil, fmt.Errorf("%w %d for job %s", queue.ErrInvalidPriority, job.Priority, job.ID)
+	}
+
+	payload, err := json.Marshal(job.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("encode payload: %w", err)
+	}
+
+	var expiresAt string
+	if !job.ExpiresAt.IsZero() {
+		expiresAt = formatTime(job.ExpiresAt)
+	}
+
+	return []any{job.ID, job.Type, job.Queue, string(payload), int(job.Priority), job.RetryCount, job.MaxRetries,
+		formatTime(job.CreatedAt), job.UniqueKey, expiresAt, job.DeadReason, int64(job.Timeout)}, nil
+}
+
+// held is the condition on claims under which a claim still holds its key
+// at the time given as its one argument.
+const held = `(expires_at > ?
+	OR EXISTS (SELECT 1 FROM jobs WHERE jobs.id = claims.job_id AND dead_letter = 0)
+	OR EXISTS (SELECT 1 FROM scheduled WHERE scheduled.id = claims.job_id))`
+
+// claim takes claims in tx, failing with queue.ErrDuplicateJob when one of
+// their keys is held by another job. It is called once the jobs themselves
+// are written, so that a job of the same write counts as live.
+func claim(tx *sql.Tx, claims []queue.Claim) error {
+	for _, c := range claims {
+		var holder string
+		err := tx.QueryRow(`SELECT job_id FROM claims WHERE unique_key = ? AND job_id != ? AND `+held,
+			c.Key, c.JobID, formatTime(c.At)).Scan(&holder)
+		if err == nil {
+			return fmt.Errorf("%w: job %s has the unique key %q of job %s", queue.ErrDuplicateJob, c.JobID, c.Key, holder)
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		_, err = tx.Exec(`
+			INSERT INTO claims (unique_key, job_id, expires_at) VALUES (?, ?, ?)
+			ON CONFLICT (unique_key) DO UPDATE SET
+				job_id = excluded.job_id,
+				expires_at = excluded.expires_at
+			WHERE job_id != excluded.job_id`,
+			c.Key, c.JobID, formatTime(c.Until))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unschedule forgets the schedule of the job with the given ID, if any.
+func unschedule(db execer, id string) error {
+	_, err := db.Exec(`DELETE FROM scheduled WHERE id = ?`, id)
+	return err
+}
+
+// pop deletes and returns the oldest queued job of the given queue and
+// priority whose type is not in skipTypes, in a single statement.
+func (s *Storage) pop(queueName string, priority utils.Priority, deadLetter bool, skipTypes []string) (utils.Job, bool, error) {
+	skip, args := notIn("type", skipTypes)
+	row := s.db.QueryRow(`
+		DELETE FROM jobs WHERE seq = (
+			SELECT seq FROM jobs
+			WHERE queue = ? AND dead_letter = ? AND in_flight = 0 AND priority = ?`+skip+`
+			ORDER BY created_at, seq
+			LIMIT 1
+		)
+		RETURNING `+columns, append([]any{queueName, deadLetter, int(priority)}, args...)...)
+	return scanOne(row)
+}
+
+func (s *Storage) list(queueName string, priority utils.Priority, deadLetter bool) ([]utils.Job, error) {
+	rows, err := s.db.Query(`
+		SELECT `+columns+` FROM jobs
+		WHERE queue = ? AND dead_letter = ? AND in_flight = 0 AND priority = ?
+		ORDER BY created_at, seq`, queueName, deadLetter, int(priority))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := make([]utils.Job, 0)
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+func (s *Storage) priorities(queueName string, deadLetter bool) ([]utils.Priority, error) {
+	rows, err := s.db.Query(`
+		SELECT DISTINCT priority FROM jobs
+		WHERE queue = ? AND dead_letter = ? AND in_flight = 0
+		ORDER BY priority`, queueName, deadLetter)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ps := make([]utils.Priority, 0)
+	for rows.Next() {
+		var p int
+		if err := rows.Scan(&p); err != nil {
+			return nil, err
+		}
+		ps = append(ps, utils.Priority(p))
+	}
+	return ps, rows.Err()
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanOne(row scanner) (utils.Job, bool, error) {
+	job, err := scanJob(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return utils.Job{}, false, nil
+	}
+	if err != nil {
+		return utils.Job{}, false, err
+	}
+	return job, true, nil
+}
+
+// scanJob decodes a row selected with columns, followed by any extra
+// destinations.
+func scanJob(row scanner, extra ...any) (utils.Job, error) {
+	var (
+		job       utils.Job
+		payload   string
+		priority  int
+		createdAt string
+		expiresAt string
+	)
+	dest := append([]any{&job.ID, &job.Type, &job.Queue, &payload, &priority, &job.RetryCount, &job.MaxRetries, &createdAt, &job.UniqueKey, &expiresAt, &job.DeadReason, &job.Timeout}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return utils.Job{}, err
+	}
+	if err := json.Unmarshal([]byte(payload), &job.Payload); err != nil {
+		return utils.Job{}, fmt.Errorf("decode payload of job %s: %w", job.ID, err)
+	}
+	t, err := time.Parse(timeLayout, createdAt)
+	if err != nil {
+		return utils.Job{}, fmt.Errorf("decode created_at of job %s: %w", job.ID, err)
+	}
+	if expiresAt != "" {
+		if job.ExpiresAt, err = time.Parse(timeLayout, expiresAt); err != nil {
+			return utils.Job{}, fmt.Errorf("decode expires_at of job %s: %w", job.ID, err)
+		}
+	}
+	job.Priority = utils.Priority(priority)
+	job.CreatedAt = t
+	return job, nil
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(timeLayout)
+}
+
+func (s *Storage) SaveResult(r queue.Result) error {
+	_, err := s.db.Exec(`
+		INSERT INTO results (job_id, data, created_at, expires_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (job_id) DO UPDATE SET
+			data = excluded.data,
+			created_at = excluded.created_at,
+			expires_at = excluded.expires_at`,
+		r.JobID, r.Data, formatTime(r.CreatedAt), formatTime(r.ExpiresAt))
+	return err
+}
+
+func (s *Storage) Result(jobID string) (queue.Result, bool, error) {
+	row := s.db.QueryRow(`SELECT job_id, data, created_at, expires_at FROM results WHERE job_id = ?`, jobID)
+	r, err := scanResult(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return queue.Result{}, false, nil
+	}
+	if err != nil {
+		return queue.Result{}, false, err
+	}
+	return r, true, nil
+}
+
+func (s *Storage) Results() ([]queue.Result, error) {
+	rows, err := s.db.Query(`SELECT job_id, data, created_at, expires_at FROM results ORDER BY created_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []queue.Result
+	for rows.Next() {
+		r, err := scanResult(rows)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+func (s *Storage) PruneResults(now time.Time) error {
+	_, err := s.db.Exec(`DELETE FROM results WHERE expires_at <= ?`, formatTime(now))
+	return err
+}
+
+func scanResult(row scanner) (queue.Result, error) {
+	var r queue.Result
+	var createdAt, expiresAt string
+	if err := row.Scan(&r.JobID, &r.Data, &createdAt, &expiresAt); err != nil {
+		return queue.Result{}, err
+	}
+	var err error
+	if r.CreatedAt, err = time.Parse(timeLayout, createdAt); err != nil {
+		return queue.Result{}, fmt.Errorf("decode created_at of result %s: %w", r.JobID, err)
+	}
+	if r.ExpiresAt, err = time.Parse(timeLayout, expiresAt); err != nil {
+		return queue.Result{}, fmt.Errorf("decode expires_at of result %s: %w", r.JobID, err)
+	}
+	return r, nil
+}
+
+func (s *Storage) AddPause(p queue.Pause) error {
+	_, err := s.db.Exec(`INSERT INTO pauses (queue, priority, type) VALUES (?, ?, ?) ON CONFLICT DO NOTHING`,
+		p.Queue, int(p.Priority), p.Type)
+	return err
+}
+
+func (s *Storage) RemovePause(p queue.Pause) error {
+	_, err := s.db.Exec(`DELETE FROM pauses WHERE queue = ? AND priority = ? AND type = ?`, p.Queue, int(p.Priority), p.Type)
+	return err
+}
+
+func (s *Storage) Pauses() ([]queue.Pause, error) {
+	rows, err := s.db.Query(`SELECT queue, priority, type FROM pauses ORDER BY queue, priority, type`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pauses := make([]queue.Pause, 0)
+	for rows.Next() {
+		var p queue.Pause
+		var priority int
+		if err := rows.Scan(&p.Queue, &priority, &p.Type); err != nil {
+			return nil, err
+		}
+		p.Priority = utils.Priority(priority)
+		pauses = append(pauses, p)
+	}
+	return pauses, rows.Err()
+}
+
+func (s *Storage) Schedule(sj queue.ScheduledJob, claims []queue.Claim) error {
+	args, err := jobArgs(sj.Job)
+	if err != nil {
+		return err
+	}
+	return s.update(func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+			INSERT OR REPLACE INTO scheduled (`+columns+`, run_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			append(args, formatTime(sj.ScheduleTime))...)
+		if err != nil {
+			return err
+		}
+		return claim(tx, claims)
+	})
+}
+
+func (s *Storage) ScheduledJob(id string) (queue.ScheduledJob, bool, error) {
+	sj, err := scanScheduled(s.db.QueryRow(`SELECT `+columns+`, run_at FROM scheduled WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return queue.ScheduledJob{}, false, nil
+	}
+	if err != nil {
+		return queue.ScheduledJob{}, false, err
+	}
+	return sj, true, nil
+}
+
+func (s *Storage) ScheduledJobs() ([]queue.ScheduledJob, error) {
+	rows, err := s.db.Query(`SELECT ` + columns + `, run_at FROM scheduled ORDER BY run_at, id`)
+	if err != nil {
+		return nil, err
+	}
+	return collectScheduled(rows)
+}
+
+// TakeDue deletes the due schedules and returns them in one statement, so
+// that another process sharing the file cannot take them as well.
+func (s *Storage) TakeDue(now time.Time) ([]queue.ScheduledJob, error) {
+	rows, err := s.db.Query(`
+		DELETE FROM scheduled
+		WHERE run_at <= ? OR (expires_at != '' AND expires_at <= ?)
+		RETURNING `+columns+`, run_at`, formatTime(now), formatTime(now))
+	if err != nil {
+		return nil, err
+	}
+	due, err := collectScheduled(rows)
+	if err != nil {
+		return nil, err
+	}
+	// RETURNING yields rows in no particular order.
+	sort.SliceStable(due, func(i, j int) bool { return due[i].ScheduleTime.Before(due[j].ScheduleTime) })
+	return due, nil
+}
+
+func collectScheduled(rows *sql.Rows) ([]queue.ScheduledJob, error) {
+	defer rows.Close()
+
+	jobs := make([]queue.ScheduledJob, 0)
+	for rows.Next() {
+		sj, err := scanScheduled(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, sj)
+	}
+	return jobs, rows.Err()
+}
+
+func scanScheduled(row scanner) (queue.ScheduledJob, error) {
+	var runAt string
+	job, err := scanJob(row, &runAt)
+	if err != nil {
+		return queue.ScheduledJob{}, err
+	}
+	t, err := time.Parse(timeLayout, runAt)
+	if err != nil {
+		return queue.ScheduledJob{}, fmt.Errorf("decode run_at of job %s: %w", job.ID, err)
+	}
+	return queue.ScheduledJob{Job: job, ScheduleTime: t}, nil
+}
+
+func (s *Storage) PruneClaims(now time.Time) error {
+	_, err := s.db.Exec(`DELETE FROM claims WHERE expires_at <= ? AND NOT `+held, formatTime(now), formatTime(now))
+	return err
+}
@@ -0,0 +1,499 @@
+package sqlite
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Avik-creator/queue"
+	"github.com/Avik-creator/utils"
+)
+
+func openTestStorage(t *testing.T, path string) *Storage {
+	t.Helper()
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	return s
+}
+
+func TestDequeueOrder(t *testing.T) {
+	s := openTestStorage(t, filepath.Join(t.TempDir(), "jobs.db"))
+	defer s.Close()
+
+	now := time.Now()
+	q := queue.NewQueue(queue.WithStorage(s))
+	q.AddJob(utils.Job{ID: "low", Priority: utils.Low, CreatedAt: now})
+	q.AddJob(utils.Job{ID: "high-newer", Priority: utils.High, CreatedAt: now.Add(time.Second)})
+	q.AddJob(utils.Job{ID: "high-older", Priority: utils.High, CreatedAt: now})
+	q.AddJob(utils.Job{ID: "medium", Priority: utils.Medium, CreatedAt: now})
+
+	expected := []string{"high-older", "high-newer", "medium", "low"}
+	for _, id := range expected {
+		job, err := q.GetJob()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if job.ID != id {
+			t.Errorf("Expected %s, got %s", id, job.ID)
+		}
+	}
+
+	if _, err := q.GetJob(); err == nil {
+		t.Error("Expected error when queue is empty")
+	}
+}
+
+func TestJobRoundTrip(t *testing.T) {
+	s := openTestStorage(t, filepath.Join(t.TempDir(), "jobs.db"))
+	defer s.Close()
+
+	job := utils.Job{
+		ID:         "job1",
+		Type:       "email",
+		Queue:      "emails",
+		Payload:    map[string]string{"to": "user@example.com"},
+		Priority:   utils.Medium,
+		RetryCount: 2,
+		MaxRetries: 5,
+		CreatedAt:  time.Now(),
+		UniqueKey:  "welcome:42",
+		ExpiresAt:  time.Now().Add(time.Hour),
+		DeadReason: "expired",
+		Timeout:    30 * time.Second,
+	}
+	if err := s.Enqueue(job); err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+
+	got, ok, err := s.Dequeue("emails", utils.Medium, time.Now())
+	if err != nil || !ok {
+		t.Fatalf("Expected a job, got ok=%v err=%v", ok, err)
+	}
+	if got.ID != job.ID || got.Type != job.Type || got.Queue != job.Queue || got.Payload["to"] != "user@example.com" ||
+		got.RetryCount != 2 || got.MaxRetries != 5 || !got.CreatedAt.Equal(job.CreatedAt) || got.UniqueKey != job.UniqueKey ||
+		!got.ExpiresAt.Equal(job.ExpiresAt) || got.DeadReason != job.DeadReason || got.Timeout != job.Timeout {
+		t.Errorf("Job did not round-trip: got %+v, want %+v", got, job)
+	}
+}
+
+func TestDeadLetterAndRemove(t *testing.T) {
+	s := openTestStorage(t, filepath.Join(t.TempDir(), "jobs.db"))
+	defer s.Close()
+
+	q := queue.NewQueue(queue.WithStorage(s))
+	job1 := utils.Job{ID: "job1", Priority: utils.High, CreatedAt: time.Now()}
+	job2 := utils.Job{ID: "job2", Priority: utils.High, CreatedAt: time.Now()}
+	job3 := utils.Job{ID: "job3", Priority: utils.Low, CreatedAt: time.Now()}
+
+	q.AddJob(job1)
+	q.AddJob(job2)
+	q.AddJob(job3)
+	q.MoveJobToDeadLetterQueue(job1)
+	q.RemoveJobFromQueue(job3)
+
+	highJobs, _, lowJobs, err := q.GetAllJobs()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(highJobs) != 1 || highJobs[0].ID != "job2" {
+		t.Errorf("Expected only job2 in high priority queue, got %v", highJobs)
+	}
+	if len(lowJobs) != 0 {
+		t.Errorf("Expected low priority queue to be empty, got %v", lowJobs)
+	}
+
+	deadHigh, _, _, err := q.GetAllDeadLetterJobs()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(deadHigh) != 1 || deadHigh[0].ID != "job1" {
+		t.Errorf("Expected job1 in dead letter queue, got %v", deadHigh)
+	}
+
+	job, err := q.GetDeadLetterJob()
+	if err != nil || job.ID != "job1" {
+		t.Errorf("Expected to get job1 from dead letter queue, got %s (%v)", job.ID, err)
+	}
+}
+
+func TestPersistsAcrossReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.db")
+
+	s := openTestStorage(t, path)
+	q := queue.NewQueue(queue.WithStorage(s))
+	q.AddJob(utils.Job{ID: "job1", Priority: utils.High, CreatedAt: time.Now()})
+	q.AddJob(utils.Job{ID: "job2", Priority: utils.Low, CreatedAt: time.Now()})
+	q.MoveJobToDeadLetterQueue(utils.Job{ID: "job2", Priority: utils.Low, RetryCount: 3})
+	q.Close()
+
+	s = openTestStorage(t, path)
+	defer s.Close()
+	q = queue.NewQueue(queue.WithStorage(s))
+
+	highJobs, _, _, _ := q.GetAllJobs()
+	if len(highJobs) != 1 || highJobs[0].ID != "job1" {
+		t.Errorf("Expected job1 after reopening, got %v", highJobs)
+	}
+	_, _, deadLow, _ := q.GetAllDeadLetterJobs()
+	if len(deadLow) != 1 || deadLow[0].RetryCount != 3 {
+		t.Errorf("Expected job2 with RetryCount 3 in dead letter queue, got %v", deadLow)
+	}
+}
+
+func TestListExpired(t *testing.T) {
+	s := openTestStorage(t, filepath.Join(t.TempDir(), "jobs.db"))
+	defer s.Close()
+
+	now := time.Now()
+	s.Hold(utils.Job{ID: "job1", Queue: utils.DefaultQueue, Priority: utils.High}, now.Add(-time.Second))
+	s.Hold(utils.Job{ID: "job2", Queue: utils.DefaultQueue, Priority: utils.High}, now.Add(time.Hour))
+	s.Hold(utils.Job{ID: "job3", Queue: utils.DefaultQueue, Priority: utils.Low}, now.Add(-2*time.Second))
+	// Extending job3 moves its deadline past now.
+	s.Hold(utils.Job{ID: "job3", Queue: utils.DefaultQueue, Priority: utils.Low}, now.Add(2*time.Hour))
+
+	held, err := s.ListExpired(now)
+	if err != nil || len(held) != 1 || held[0].Job.ID != "job1" {
+		t.Fatalf("Expected only job1 to have expired, got %v (%v)", held, err)
+	}
+	if until, ok, err := s.NextDeadline(); err != nil || !ok || !until.Equal(held[0].Until) {
+		t.Errorf("Expected the next deadline to be job1's, got %v %v (%v)", until, ok, err)
+	}
+
+	s.Ack(utils.Job{ID: "job1"})
+	if held, _ := s.ListExpired(now); len(held) != 0 {
+		t.Errorf("Expected no expired leases after Ack, got %v", held)
+	}
+	if until, ok, _ := s.NextDeadline(); !ok || !until.Equal(now.Add(time.Hour)) {
+		t.Errorf("Expected job2's deadline next, got %v %v", until, ok)
+	}
+
+	s.Enqueue(utils.Job{ID: "job2", Queue: utils.DefaultQueue, Priority: utils.High})
+	s.MoveToDeadLetter(utils.Job{ID: "job3", Queue: utils.DefaultQueue, Priority: utils.Low})
+	if _, ok, _ := s.NextDeadline(); ok {
+		t.Errorf("Expected no deadline with nothing in flight")
+	}
+}
+
+func TestEnqueueBatchIsAllOrNothing(t *testing.T) {
+	s := openTestStorage(t, filepath.Join(t.TempDir(), "jobs.db"))
+	defer s.Close()
+
+	err := s.EnqueueBatch([]utils.Job{
+		{ID: "job1", Queue: utils.DefaultQueue, Priority: utils.High},
+		{ID: "bad", Queue: utils.DefaultQueue},
+	}, nil)
+	if !errors.Is(err, queue.ErrInvalidPriority) {
+		t.Fatalf("Expected ErrInvalidPriority, got %v", err)
+	}
+	if n, _ := s.Len(utils.DefaultQueue, utils.High); n != 0 {
+		t.Errorf("Expected no job of a failed batch to be stored, got %d", n)
+	}
+
+	err = s.EnqueueBatch([]utils.Job{
+		{ID: "job1", Queue: utils.DefaultQueue, Priority: utils.High},
+		{ID: "job2", Queue: utils.DefaultQueue, Priority: utils.High},
+	}, nil)
+	if n, _ := s.Len(utils.DefaultQueue, utils.High); err != nil || n != 2 {
+		t.Errorf("Expected 2 jobs, got %d (%v)", n, err)
+	}
+}
+
+func TestJobByID(t *testing.T) {
+	s := openTestStorage(t, filepath.Join(t.TempDir(), "jobs.db"))
+	defer s.Close()
+
+	s.Enqueue(utils.Job{ID: "queued", Queue: "emails", Priority: utils.High, Payload: map[string]string{"to": "a"}})
+	s.Hold(utils.Job{ID: "held", Queue: utils.DefaultQueue, Priority: utils.Low}, time.Now().Add(time.Minute))
+	s.MoveToDeadLetter(utils.Job{ID: "dead", Queue: utils.DefaultQueue, Priority: utils.Medium})
+
+	for _, id := range []string{"queued", "held", "dead"} {
+		if job, ok, err := s.Job(id); err != nil || !ok || job.ID != id {
+			t.Errorf("Expected to find %s, got %+v ok=%v (%v)", id, job, ok, err)
+		}
+	}
+	if job, _, _ := s.Job("queued"); job.Queue != "emails" || job.Payload["to"] != "a" {
+		t.Errorf("Expected the whole job, got %+v", job)
+	}
+	if _, ok, err := s.Job("missing"); ok || err != nil {
+		t.Errorf("Expected no missing job, got ok=%v (%v)", ok, err)
+	}
+}
+
+func TestRejectsInvalidPriority(t *testing.T) {
+	s := openTestStorage(t, filepath.Join(t.TempDir(), "jobs.db"))
+	defer s.Close()
+
+	for _, p := range []utils.Priority{0, -1, utils.MaxPriority + 1} {
+		if err := s.Enqueue(utils.Job{ID: "job1", Queue: utils.DefaultQueue, Priority: p}); !errors.Is(err, queue.ErrInvalidPriority) {
+			t.Errorf("Expected ErrInvalidPriority for priority %d, got %v", p, err)
+		}
+	}
+}
+
+func TestArbitraryPriorities(t *testing.T) {
+	s := openTestStorage(t, filepath.Join(t.TempDir(), "jobs.db"))
+	defer s.Close()
+
+	now := time.Now()
+	s.Enqueue(utils.Job{ID: "p42-late", Queue: utils.DefaultQueue, Priority: 42, CreatedAt: now})
+	s.Enqueue(utils.Job{ID: "p7", Queue: utils.DefaultQueue, Priority: 7, CreatedAt: now})
+	s.Enqueue(utils.Job{ID: "p42-early", Queue: utils.DefaultQueue, Priority: 42, CreatedAt: now.Add(-time.Minute)})
+
+	ps, err := s.Priorities(utils.DefaultQueue)
+	if err != nil || len(ps) != 2 || ps[0] != 7 || ps[1] != 42 {
+		t.Fatalf("Expected priorities [7 42], got %v (%v)", ps, err)
+	}
+	job, ok, err := s.Dequeue(utils.DefaultQueue, 42, time.Time{})
+	if err != nil || !ok || job.ID != "p42-early" {
+		t.Errorf("Expected the earlier-created job first, got %s ok=%v err=%v", job.ID, ok, err)
+	}
+}
+
+func TestPeek(t *testing.T) {
+	s := openTestStorage(t, filepath.Join(t.TempDir(), "jobs.db"))
+	defer s.Close()
+
+	now := time.Now()
+	s.Enqueue(utils.Job{ID: "late", Queue: "emails", Priority: utils.Low, CreatedAt: now})
+	s.Enqueue(utils.Job{ID: "early", Queue: "emails", Priority: utils.Low, CreatedAt: now.Add(-time.Minute)})
+
+	job, ok, err := s.Peek("emails", utils.Low)
+	if err != nil || !ok || job.ID != "early" || job.Queue != "emails" {
+		t.Fatalf("Expected to peek at early in emails, got %+v ok=%v err=%v", job, ok, err)
+	}
+	if job, ok, err := s.Dequeue("emails", utils.Low, time.Time{}); err != nil || !ok || job.ID != "early" {
+		t.Errorf("Expected Peek to leave early queued, got %s ok=%v err=%v", job.ID, ok, err)
+	}
+	if _, ok, err := s.Peek("emails", utils.High); err != nil || ok {
+		t.Errorf("Expected nothing to peek at in an empty priority, got ok=%v err=%v", ok, err)
+	}
+}
+
+func TestLeasedJobReturnsAfterReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.db")
+
+	s := openTestStorage(t, path)
+	q := queue.NewQueue(queue.WithStorage(s))
+	q.AddJob(utils.Job{ID: "job1", Priority: utils.High, CreatedAt: time.Now()})
+	if _, err := q.Lease(50 * time.Millisecond); err != nil {
+		t.Fatalf("Lease failed: %v", err)
+	}
+	// Simulate a worker crash: the lease is never acked.
+	q.Close()
+
+	s = openTestStorage(t, path)
+	defer s.Close()
+	q = queue.NewQueue(queue.WithStorage(s))
+
+	if _, err := q.Lease(time.Second); err == nil {
+		t.Fatal("Expected leased job to stay hidden until its lease expires")
+	}
+	time.Sleep(60 * time.Millisecond)
+
+	l, err := q.Lease(time.Second)
+	if err != nil {
+		t.Fatalf("Expected expired lease to be requeued, got error: %v", err)
+	}
+	if l.Job.ID != "job1" {
+		t.Errorf("Expected job1, got %s", l.Job.ID)
+	}
+	if err := q.Ack(l); err != nil {
+		t.Fatalf("Ack failed: %v", err)
+	}
+
+	held, _ := s.ListInFlight()
+	highJobs, _ := s.List(utils.DefaultQueue, utils.High)
+	if len(held) != 0 || len(highJobs) != 0 {
+		t.Errorf("Expected acked job to be deleted, got in-flight=%v queued=%v", held, highJobs)
+	}
+}
+
+func TestResults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.db")
+	s := openTestStorage(t, path)
+
+	now := time.Now()
+	s.SaveResult(queue.Result{JobID: "old", Data: []byte("1"), CreatedAt: now.Add(-time.Hour), ExpiresAt: now.Add(-time.Minute)})
+	s.SaveResult(queue.Result{JobID: "new", Data: []byte("2"), CreatedAt: now, ExpiresAt: now.Add(time.Hour)})
+	s.Close()
+
+	s = openTestStorage(t, path)
+	defer s.Close()
+
+	r, ok, err := s.Result("new")
+	if err != nil || !ok || string(r.Data) != "2" || !r.ExpiresAt.Equal(now.Add(time.Hour)) {
+		t.Fatalf("Expected the result of new after reopening, got %+v ok=%v err=%v", r, ok, err)
+	}
+	if err := s.PruneResults(now); err != nil {
+		t.Fatalf("PruneResults failed: %v", err)
+	}
+	if _, ok, _ := s.Result("old"); ok {
+		t.Errorf("Expected the expired result to be pruned")
+	}
+	if rs, _ := s.Results(); len(rs) != 1 || rs[0].JobID != "new" {
+		t.Errorf("Expected only new to be left, got %v", rs)
+	}
+}
+
+func TestPauses(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.db")
+	s := openTestStorage(t, path)
+
+	now := time.Now()
+	s.Enqueue(utils.Job{ID: "email1", Type: "email", Queue: "q", Priority: utils.High, CreatedAt: now})
+	s.Enqueue(utils.Job{ID: "sms1", Type: "sms", Queue: "q", Priority: utils.High, CreatedAt: now.Add(time.Millisecond)})
+	s.AddPause(queue.Pause{Type: "email"})
+	s.AddPause(queue.Pause{Type: "email"})
+	s.AddPause(queue.Pause{Queue: "q", Priority: utils.Low})
+	s.Close()
+
+	s = openTestStorage(t, path)
+	defer s.Close()
+
+	pauses, err := s.Pauses()
+	if err != nil || len(pauses) != 2 {
+		t.Fatalf("Expected two pauses after reopening, got %v (%v)", pauses, err)
+	}
+	job, ok, err := s.DequeueSkipping("q", utils.High, []string{"email"}, time.Now().Add(time.Minute))
+	if err != nil || !ok || job.ID != "sms1" {
+		t.Fatalf("Expected sms1 past the skipped email job, got %s ok=%v err=%v", job.ID, ok, err)
+	}
+	if _, ok, _ := s.DequeueSkipping("q", utils.High, []string{"email"}, time.Time{}); ok {
+		t.Errorf("Expected only skipped jobs to be left")
+	}
+
+	s.RemovePause(queue.Pause{Type: "email"})
+	if pauses, _ := s.Pauses(); len(pauses) != 1 || pauses[0] != (queue.Pause{Queue: "q", Priority: utils.Low}) {
+		t.Errorf("Expected one pause left, got %v", pauses)
+	}
+}
+
+func TestSchedules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.db")
+	s := openTestStorage(t, path)
+
+	now := time.Now()
+	q := queue.NewQueue(queue.WithStorage(s))
+	for i, id := range []string{"later", "soon", "added"} {
+		job := utils.Job{ID: id, Priority: utils.High, CreatedAt: now, Payload: map[string]string{"n": id}}
+		if err := q.Schedule(job, now.Add(time.Duration(3-i)*time.Minute)); err != nil {
+			t.Fatalf("Schedule(%s) failed: %v", id, err)
+		}
+	}
+	if err := q.AddJob(utils.Job{ID: "added", Priority: utils.High, CreatedAt: now}); err != nil {
+		t.Fatalf("AddJob failed: %v", err)
+	}
+	s.Close()
+
+	// Another process opening the file sees the schedules.
+	s = openTestStorage(t, path)
+	defer s.Close()
+	other := openTestStorage(t, path)
+	defer other.Close()
+
+	scheduled, err := s.ScheduledJobs()
+	if err != nil || len(scheduled) != 2 || scheduled[0].Job.ID != "soon" || scheduled[1].Job.ID != "later" {
+		t.Fatalf("Expected soon and later after reopening, got %v (%v)", scheduled, err)
+	}
+	if sj, ok, err := s.ScheduledJob("soon"); err != nil || !ok || !sj.ScheduleTime.Equal(now.Add(2*time.Minute)) || sj.Job.Payload["n"] != "soon" {
+		t.Errorf("Expected the schedule of soon, got %+v ok=%v err=%v", sj, ok, err)
+	}
+
+	due, err := other.TakeDue(now.Add(2 * time.Minute))
+	if err != nil || len(due) != 1 || due[0].Job.ID != "soon" {
+		t.Fatalf("Expected to take soon, got %v (%v)", due, err)
+	}
+	if due, _ := s.TakeDue(now.Add(2 * time.Minute)); len(due) != 0 {
+		t.Errorf("Expected soon to be taken only once, got %v", due)
+	}
+
+	// A job that expires before it is due is taken once it expires.
+	s.Schedule(queue.ScheduledJob{Job: utils.Job{ID: "stale", Priority: utils.High, CreatedAt: now, ExpiresAt: now.Add(time.Minute)}, ScheduleTime: now.Add(time.Hour)}, nil)
+	if due, _ := s.TakeDue(now.Add(time.Minute)); len(due) != 1 || due[0].Job.ID != "stale" {
+		t.Errorf("Expected to take the expired job, got %v", due)
+	}
+
+	if err := s.Remove(utils.Job{ID: "later"}); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if scheduled, _ := other.ScheduledJobs(); len(scheduled) != 0 {
+		t.Errorf("Expected no schedules left, got %v", scheduled)
+	}
+}
+
+func TestUniqueKeysAreShared(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.db")
+	s1 := openTestStorage(t, path)
+	defer s1.Close()
+	s2 := openTestStorage(t, path)
+	defer s2.Close()
+
+	// Two processes sharing the file, each with its own queue.
+	q1 := queue.NewQueue(queue.WithStorage(s1), queue.WithUniqueness(50*time.Millisecond, queue.RejectDuplicates))
+	q2 := queue.NewQueue(queue.WithStorage(s2), queue.WithUniqueness(50*time.Millisecond, queue.RejectDuplicates))
+	job := func(id string) utils.Job {
+		return utils.Job{ID: id, Priority: utils.High, CreatedAt: time.Now(), UniqueKey: "k1"}
+	}
+
+	if err := q1.AddJob(job("job1")); err != nil {
+		t.Fatalf("AddJob failed: %v", err)
+	}
+	if err := q2.AddJob(job("job2")); !errors.Is(err, queue.ErrDuplicateJob) {
+		t.Errorf("Expected ErrDuplicateJob while job1 is queued, got %v", err)
+	}
+	if _, err := q1.GetJob(); err != nil {
+		t.Fatalf("GetJob failed: %v", err)
+	}
+	if err := q2.AddJob(job("job2")); !errors.Is(err, queue.ErrDuplicateJob) {
+		t.Errorf("Expected ErrDuplicateJob within the window of job1, got %v", err)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if err := q2.AddJob(job("job2")); err != nil {
+		t.Fatalf("Expected the key to be free once the window passed, got %v", err)
+	}
+	if err := q1.Schedule(job("job3"), time.Now().Add(time.Hour)); !errors.Is(err, queue.ErrDuplicateJob) {
+		t.Errorf("Expected ErrDuplicateJob for a key taken by the other process, got %v", err)
+	}
+}
+
+func TestConcurrentUniqueEnqueues(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.db")
+	var qs []*queue.JobQueue
+	for range 2 {
+		s := openTestStorage(t, path)
+		defer s.Close()
+		qs = append(qs, queue.NewQueue(queue.WithStorage(s)))
+	}
+
+	var mu sync.Mutex
+	var added int
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			job := utils.Job{ID: fmt.Sprintf("job%d", i), Priority: utils.High, CreatedAt: time.Now(), UniqueKey: "k1"}
+			err := qs[i%2].AddJob(job)
+			if err != nil && !errors.Is(err, queue.ErrDuplicateJob) {
+				t.Errorf("AddJob failed: %v", err)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				added++
+			}
+		}()
+	}
+	wg.Wait()
+
+	if added != 1 {
+		t.Errorf("Expected exactly one job to take the key, got %d", added)
+	}
+}
@@ -0,0 +1,105 @@
+package queue
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Avik-creator/utils"
+)
+
+// ErrInvalidPriority is returned for a job whose priority is outside
+// utils.MinPriority to utils.MaxPriority.
+var ErrInvalidPriority = errors.New("invalid priority")
+
+// Storage holds the live and dead-letter jobs behind a JobQueue, along with
+// the jobs currently leased to a worker and the jobs scheduled for later.
+// Jobs are kept per named queue, given by Job.Queue, and within a queue per
+// priority, ordered by CreatedAt and then by the order they were added. JobQueue serialises every call, so
+// implementations need not be safe for concurrent use on their own.
+type Storage interface {
+	// Enqueue adds job to its queue's priority bucket. A job that is
+	// currently in flight or scheduled under the same ID is taken out of
+	// flight or off the schedule. Jobs with an invalid priority are rejected
+	// with ErrInvalidPriority.
+	Enqueue(job utils.Job) error
+	// EnqueueBatch enqueues every job as Enqueue would and takes claims, in
+	// a single write: either all of them are stored or, on error, none is.
+	// It fails with ErrDuplicateJob when a claimed key is held by another
+	// job; see Claim.
+	EnqueueBatch(jobs []utils.Job, claims []Claim) error
+	// Dequeue takes the first job of the given queue and priority out of
+	// its bucket and holds it in flight until leaseUntil, or drops it
+	// outright when leaseUntil is zero. ok is false when there is no such
+	// job.
+	Dequeue(queue string, priority utils.Priority, leaseUntil time.Time) (job utils.Job, ok bool, err error)
+	// DequeueSkipping is Dequeue that passes over jobs whose Type is in
+	// skipTypes, leaving them where they are.
+	DequeueSkipping(queue string, priority utils.Priority, skipTypes []string, leaseUntil time.Time) (job utils.Job, ok bool, err error)
+	// Job returns the job with the given ID, whether queued, in flight or
+	// dead-lettered.
+	Job(id string) (job utils.Job, ok bool, err error)
+	// Peek returns the job Dequeue would take, without taking it.
+	Peek(queue string, priority utils.Priority) (job utils.Job, ok bool, err error)
+	// Len returns the number of queued jobs of the given queue and
+	// priority.
+	Len(queue string, priority utils.Priority) (int, error)
+	// Hold keeps job in flight until until, replacing any copy of the job
+	// already in flight and its deadline.
+	Hold(job utils.Job, until time.Time) error
+	// Ack forgets the in-flight job with job's ID.
+	Ack(job utils.Job) error
+	ListInFlight() ([]HeldJob, error)
+	// ListExpired returns the in-flight jobs whose lease runs out no later
+	// than now, soonest first.
+	ListExpired(now time.Time) ([]HeldJob, error)
+	// NextDeadline returns the soonest time a lease on an in-flight job runs
+	// out; ok is false when nothing is in flight.
+	NextDeadline() (until time.Time, ok bool, err error)
+	// Remove takes job out of its bucket or off the schedule.
+	Remove(job utils.Job) error
+	// MoveToDeadLetter removes job from its bucket, from flight or from the
+	// schedule and adds it to the dead-letter queue.
+	MoveToDeadLetter(job utils.Job) error
+	DequeueDeadLetter(queue string, priority utils.Priority) (job utils.Job, ok bool, err error)
+	List(queue string, priority utils.Priority) ([]utils.Job, error)
+	ListDeadLetter(queue string, priority utils.Priority) ([]utils.Job, error)
+	// Queues returns the sorted names of the queues that hold jobs, whether
+	// queued, in flight or dead-lettered.
+	Queues() ([]string, error)
+	// Priorities returns the priorities that have queued jobs in the named
+	// queue, lowest value first.
+	Priorities(queue string) ([]utils.Priority, error)
+	DeadLetterPriorities(queue string) ([]utils.Priority, error)
+	// SaveResult stores r under r.JobID, replacing any earlier result.
+	SaveResult(r Result) error
+	// Result returns the result stored under jobID, expired or not.
+	Result(jobID string) (r Result, ok bool, err error)
+	Results() ([]Result, error)
+	// PruneResults deletes the results that expire no later than now.
+	PruneResults(now time.Time) error
+	// AddPause records p, and RemovePause forgets it; neither fails if p
+	// is already recorded or missing. Pauses returns every recorded pause.
+	AddPause(p Pause) error
+	RemovePause(p Pause) error
+	Pauses() ([]Pause, error)
+	// Schedule records sj, replacing any earlier schedule of the same job,
+	// and takes claims in the same write, as EnqueueBatch does.
+	Schedule(sj ScheduledJob, claims []Claim) error
+	// ScheduledJob returns the schedule of the job with the given ID.
+	ScheduledJob(id string) (sj ScheduledJob, ok bool, err error)
+	// ScheduledJobs returns every schedule, soonest first.
+	ScheduledJobs() ([]ScheduledJob, error)
+	// TakeDue forgets the schedules due no later than now, and those of
+	// jobs that expire by then, and returns them, soonest first. Two callers
+	// sharing the storage never take the same schedule.
+	TakeDue(now time.Time) ([]ScheduledJob, error)
+	// PruneClaims forgets the claims that ran out by now and whose job is
+	// no longer scheduled, queued or in flight.
+	PruneClaims(now time.Time) error
+}
+
+// HeldJob is a job in flight and the time its lease runs out.
+type HeldJob struct {
+	Job   utils.Job `json:"job"`
+	Until time.Time `json:"until"`
+}
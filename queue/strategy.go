@@ -0,0 +1,133 @@
+package queue
+
+import (
+	"sort"
+
+	"github.com/Avik-creator/utils"
+)
+
+// Strategy decides which priority of a queue a dequeue serves next. JobQueue
+// calls it with q.mu held, so implementations may keep per-queue state
+// without locking.
+type Strategy interface {
+	// Next returns one of priorities, the priorities that have jobs waiting
+	// in the named queue, lowest value first. It is never called with an
+	// empty slice.
+	Next(queue string, priorities []utils.Priority) utils.Priority
+}
+
+// WithStrategy sets how a queue chooses between its priorities. The default
+// is Strict.
+func WithStrategy(s Strategy) Option {
+	return func(q *JobQueue) {
+		q.strategy = s
+	}
+}
+
+type strict struct{}
+
+// Strict always serves the most urgent priority with jobs waiting, so a
+// steady flow of urgent jobs can starve the rest.
+func Strict() Strategy {
+	return strict{}
+}
+
+func (strict) Next(_ string, priorities []utils.Priority) utils.Priority {
+	return priorities[0]
+}
+
+type weightedRoundRobin struct {
+	weights map[utils.Priority]int
+	current map[string]map[utils.Priority]int
+}
+
+// WeightedRoundRobin serves each priority with jobs waiting in proportion to
+// its weight, interleaving them as evenly as possible: with weights 6:3:1 for
+// High, Medium and Low, ten dequeues from a busy queue take six High, three
+// Medium and one Low job. Priorities without a weight get weight 1.
+func WeightedRoundRobin(weights map[utils.Priority]int) Strategy {
+	return &weightedRoundRobin{weights: weights, current: make(map[string]map[utils.Priority]int)}
+}
+
+func (w *weightedRoundRobin) Next(queue string, priorities []utils.Priority) utils.Priority {
+	// Smooth weighted round-robin: every waiting priority earns its weight,
+	// the richest is served and pays back the total.
+	current := w.current[queue]
+	if current == nil {
+		current = make(map[utils.Priority]int)
+		w.current[queue] = current
+	}
+	forgetIdle(current, priorities)
+
+	total := 0
+	best := priorities[0]
+	for _, p := range priorities {
+		weight := weightOf(w.weights, p)
+		current[p] += weight
+		total += weight
+		if current[p] > current[best] {
+			best = p
+		}
+	}
+	current[best] -= total
+	return best
+}
+
+type deficitRoundRobin struct {
+	quanta map[utils.Priority]int
+	queues map[string]*drrState
+}
+
+type drrState struct {
+	turn    utils.Priority
+	deficit map[utils.Priority]int
+}
+
+// DeficitRoundRobin visits the priorities with jobs waiting in turn, most
+// urgent first, and serves up to quantum jobs from each before moving on.
+// Unlike WeightedRoundRobin it serves a priority's share in one run, and a
+// priority that runs out of jobs gives up the rest of its turn. Priorities
+// without a quantum get quantum 1.
+func DeficitRoundRobin(quanta map[utils.Priority]int) Strategy {
+	return &deficitRoundRobin{quanta: quanta, queues: make(map[string]*drrState)}
+}
+
+func (d *deficitRoundRobin) Next(queue string, priorities []utils.Priority) utils.Priority {
+	st := d.queues[queue]
+	if st == nil {
+		st = &drrState{turn: priorities[len(priorities)-1], deficit: make(map[utils.Priority]int)}
+		d.queues[queue] = st
+	}
+	forgetIdle(st.deficit, priorities)
+
+	if st.deficit[st.turn] < 1 {
+		// Move on to the next waiting priority after the current one,
+		// wrapping around to the most urgent.
+		i := sort.Search(len(priorities), func(i int) bool { return priorities[i] > st.turn })
+		if i == len(priorities) {
+			i = 0
+		}
+		st.turn = priorities[i]
+		st.deficit[st.turn] += weightOf(d.quanta, st.turn)
+	}
+	st.deficit[st.turn]--
+	return st.turn
+}
+
+func weightOf(weights map[utils.Priority]int, p utils.Priority) int {
+	if w := weights[p]; w > 0 {
+		return w
+	}
+	return 1
+}
+
+// forgetIdle drops the state of priorities that no longer have jobs waiting,
+// so that they do not bank credit while idle.
+func forgetIdle(state map[utils.Priority]int, priorities []utils.Priority) {
+	for p := range state {
+		i := sort.Search(len(priorities), func(i int) bool { return priorities[i] >= p })
+		if i == len(priorities) || priorities[i] != p {
+			delete(state, p)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package queue
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Avik-creator/utils"
+)
+
+// drain fills a queue using s with n jobs at each of High, Medium and Low and
+// returns the priorities of the first k jobs dequeued, as H, M and L.
+func drain(t *testing.T, s Strategy, n, k int) string {
+	t.Helper()
+	q := NewQueue(WithStrategy(s))
+	for i := 0; i < n; i++ {
+		for _, p := range []utils.Priority{utils.High, utils.Medium, utils.Low} {
+			q.AddJob(utils.Job{ID: fmt.Sprintf("%d-%d", p, i), Priority: p, CreatedAt: time.Now()})
+		}
+	}
+
+	var order strings.Builder
+	for i := 0; i < k; i++ {
+		job, err := q.GetJob()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		order.WriteByte("?HML"[job.Priority])
+	}
+	return order.String()
+}
+
+func TestStrictStrategy(t *testing.T) {
+	if got := drain(t, Strict(), 3, 9); got != "HHHMMMLLL" {
+		t.Errorf("Expected strict priority order, got %s", got)
+	}
+}
+
+func TestWeightedRoundRobin(t *testing.T) {
+	s := WeightedRoundRobin(map[utils.Priority]int{utils.High: 6, utils.Medium: 3, utils.Low: 1})
+	got := drain(t, s, 20, 20)
+
+	if h, m, l := strings.Count(got, "H"), strings.Count(got, "M"), strings.Count(got, "L"); h != 12 || m != 6 || l != 2 {
+		t.Errorf("Expected a 12:6:2 split over 20 dequeues, got %d:%d:%d (%s)", h, m, l, got)
+	}
+	if strings.Contains(got[:10], "HHHH") {
+		t.Errorf("Expected weighted round-robin to interleave priorities, got %s", got)
+	}
+}
+
+func TestWeightedRoundRobinSkipsEmptyPriorities(t *testing.T) {
+	s := WeightedRoundRobin(map[utils.Priority]int{utils.High: 6, utils.Medium: 3, utils.Low: 1})
+	// Once High and Medium run dry Low is served every time.
+	if got := drain(t, s, 2, 6); strings.Count(got, "L") != 2 || got[5] != 'L' {
+		t.Errorf("Expected all jobs to be served, got %s", got)
+	}
+}
+
+func TestDeficitRoundRobin(t *testing.T) {
+	s := DeficitRoundRobin(map[utils.Priority]int{utils.High: 3, utils.Medium: 2, utils.Low: 1})
+	if got := drain(t, s, 10, 12); got != "HHHMMLHHHMML" {
+		t.Errorf("Expected runs of 3, 2 and 1 jobs, got %s", got)
+	}
+}
+
+func TestDeficitRoundRobinIdlePriorityLosesTurn(t *testing.T) {
+	s := DeficitRoundRobin(map[utils.Priority]int{utils.High: 3, utils.Medium: 2, utils.Low: 1})
+	// High has one job left after its first run of three and gives up the
+	// rest of its second turn.
+	if got := drain(t, s, 4, 8); got != "HHHMMLHM" {
+		t.Errorf("Expected High to give up the rest of its turn, got %s", got)
+	}
+}
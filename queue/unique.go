@@ -0,0 +1,198 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Avik-creator/utils"
+)
+
+// keySweepInterval is how often the registry looks for unique keys that no
+// job holds any more.
+const keySweepInterval = time.Minute
+
+// ErrDuplicateJob is returned by AddJob and Schedule for a job whose
+// UniqueKey is held by another job, under the RejectDuplicates policy.
+var ErrDuplicateJob = errors.New("duplicate job")
+
+// DuplicatePolicy says what AddJob and Schedule do with a job whose
+// UniqueKey is held by another job.
+type DuplicatePolicy int
+
+const (
+	// RejectDuplicates fails the call with ErrDuplicateJob.
+	RejectDuplicates DuplicatePolicy = iota
+	// MergeDuplicates folds the job into the one holding its key: the call
+	// succeeds but the job is not added.
+	MergeDuplicates
+)
+
+func (d DuplicatePolicy) String() string {
+	switch d {
+	case RejectDuplicates:
+		return "reject"
+	case MergeDuplicates:
+		return "merge"
+	}
+	return fmt.Sprintf("DuplicatePolicy(%d)", int(d))
+}
+
+// WithUniqueness sets how duplicates are handled. A job with a UniqueKey
+// holds that key while it is scheduled, queued, running or retrying, and for
+// at least window after it was first added or scheduled, so a job that
+// finished quickly still keeps its duplicates out. The default is a zero
+// window with RejectDuplicates.
+func WithUniqueness(window time.Duration, policy DuplicatePolicy) Option {
+	return func(q *JobQueue) {
+		q.jobs.window = window
+		q.duplicates = policy
+	}
+}
+
+// Claim is a job's hold on its unique key, kept by the storage so that every
+// process sharing it sees the same keys. A key is held by the job of its
+// claim while that job is scheduled, queued or in flight, and until Until
+// even once it is gone. Taking a claim on a key another job holds stores
+// nothing and fails with ErrDuplicateJob; taking it again for the same job
+// keeps the first claim.
+type Claim struct {
+	Key   string    `json:"key"`
+	JobID string    `json:"job_id"`
+	At    time.Time `json:"at"`
+	Until time.Time `json:"until"`
+}
+
+// Held reports whether c keeps another job from taking c.Key at now, given
+// whether c's job is still scheduled, queued or in flight.
+func (c Claim) Held(now time.Time, live bool) bool {
+	return live || c.Until.After(now)
+}
+
+// claim is a job holding a unique key since at.
+type claim struct {
+	id string
+	at time.Time
+}
+
+// holder returns the ID of another job holding job's unique key, if any.
+func (r *registry) holder(job utils.Job, now time.Time) (string, bool) {
+	if job.UniqueKey == "" {
+		return "", false
+	}
+	c, ok := r.keys[job.UniqueKey]
+	if !ok || c.id == job.ID {
+		return "", false
+	}
+	if r.active(c.id) || now.Sub(c.at) < r.window {
+		return c.id, true
+	}
+	delete(r.keys, job.UniqueKey)
+	return "", false
+}
+
+// active reports whether the job with the given ID is still to be run or
+// running.
+func (r *registry) active(id string) bool {
+	e, ok := r.jobs[id]
+	if !ok {
+		return false
+	}
+	switch e.state {
+	case utils.Scheduled, utils.Queued, utils.Running, utils.Retrying:
+		return true
+	}
+	return false
+}
+
+// claim makes job the holder of its unique key, unless it already is. It is
+// called by set once the job has passed holder.
+func (r *registry) claim(job utils.Job, now time.Time) {
+	if job.UniqueKey == "" {
+		return
+	}
+	if c, ok := r.keys[job.UniqueKey]; !ok || c.id != job.ID {
+		r.keys[job.UniqueKey] = claim{id: job.ID, at: now}
+	}
+}
+
+// release frees the unique key held by the job with the given ID.
+func (r *registry) release(id string) {
+	e, ok := r.jobs[id]
+	if !ok || e.uniqueKey == "" {
+		return
+	}
+	if c, ok := r.keys[e.uniqueKey]; ok && c.id == id {
+		delete(r.keys, e.uniqueKey)
+	}
+}
+
+// sweep drops keys whose holders finished more than window ago, at most once
+// per keySweepInterval.
+func (r *registry) sweep(now time.Time) {
+	if now.Sub(r.lastSweep) < keySweepInterval {
+		return
+	}
+	r.lastSweep = now
+	for key, c := range r.keys {
+		if !r.active(c.id) && now.Sub(c.at) >= r.window {
+			delete(r.keys, key)
+		}
+	}
+}
+
+// checkUnique returns nil if job may be added or scheduled as far as this
+// process knows. Otherwise it returns ErrDuplicateJob, or errMerged under
+// MergeDuplicates. The storage has the last word when the job is stored; see
+// claims. It is called with q.mu held.
+func (q *JobQueue) checkUnique(job utils.Job) error {
+	now := time.Now()
+	q.pruneClaims(now)
+	id, ok := q.jobs.holder(job, now)
+	if !ok {
+		return nil
+	}
+	if q.duplicates == MergeDuplicates {
+		return errMerged
+	}
+	return fmt.Errorf("%w: job %s has the unique key %q of job %s", ErrDuplicateJob, job.ID, job.UniqueKey, id)
+}
+
+// errMerged tells addJob and Schedule to succeed without adding a job.
+var errMerged = errors.New("merged into an existing job")
+
+// claims returns the claims jobs take on their unique keys at now, for the
+// storage to check and record along with the jobs. It is called with q.mu
+// held.
+func (q *JobQueue) claims(jobs []utils.Job, now time.Time) []Claim {
+	var claims []Claim
+	for _, job := range jobs {
+		if job.UniqueKey != "" {
+			claims = append(claims, Claim{Key: job.UniqueKey, JobID: job.ID, At: now, Until: now.Add(q.jobs.window)})
+		}
+	}
+	return claims
+}
+
+// merged turns ErrDuplicateJob from the storage into success under
+// MergeDuplicates: another process holds the key, and the job is folded into
+// its holder. It is called with q.mu held.
+func (q *JobQueue) merged(err error) error {
+	if q.duplicates == MergeDuplicates && errors.Is(err, ErrDuplicateJob) {
+		return nil
+	}
+	return err
+}
+
+// pruneClaims forgets the claims in the storage that no longer hold their
+// key, at most once per keySweepInterval. It is called with q.mu held.
+func (q *JobQueue) pruneClaims(now time.Time) {
+	if now.Sub(q.lastClaimPrune) < keySweepInterval {
+		return
+	}
+	q.lastClaimPrune = now
+	if err := q.storage.PruneClaims(now); err != nil {
+		log.Printf("queue: failed to prune unique key claims: %v", err)
+	}
+}
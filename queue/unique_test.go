@@ -0,0 +1,137 @@
+package queue
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Avik-creator/utils"
+)
+
+func uniqueJob(id, key string) utils.Job {
+	return utils.Job{ID: id, Priority: utils.High, CreatedAt: time.Now(), UniqueKey: key}
+}
+
+func TestRejectDuplicates(t *testing.T) {
+	q := NewQueue()
+	q.AddJob(uniqueJob("job1", "welcome:42"))
+
+	if err := q.AddJob(uniqueJob("job2", "welcome:42")); !errors.Is(err, ErrDuplicateJob) {
+		t.Fatalf("Expected ErrDuplicateJob for a queued key, got %v", err)
+	}
+	if err := q.Schedule(uniqueJob("job2", "welcome:42"), time.Now().Add(time.Hour)); !errors.Is(err, ErrDuplicateJob) {
+		t.Errorf("Expected ErrDuplicateJob scheduling a queued key, got %v", err)
+	}
+	if err := q.AddJob(uniqueJob("job3", "welcome:43")); err != nil {
+		t.Errorf("Expected a different key to be added, got %v", err)
+	}
+
+	l, _ := q.Lease(time.Minute)
+	if err := q.AddJob(uniqueJob("job2", "welcome:42")); !errors.Is(err, ErrDuplicateJob) {
+		t.Errorf("Expected ErrDuplicateJob for a running key, got %v", err)
+	}
+	q.Ack(l)
+	if err := q.AddJob(uniqueJob("job2", "welcome:42")); err != nil {
+		t.Errorf("Expected the key to be free once its job succeeded, got %v", err)
+	}
+}
+
+func TestJobTakenWithoutLeaseReleasesItsKey(t *testing.T) {
+	q := NewQueue()
+	q.AddJob(uniqueJob("job1", "k"))
+	q.AddJob(uniqueJob("job2", "other"))
+
+	if _, err := q.GetJobs(2); err != nil {
+		t.Fatalf("GetJobs failed: %v", err)
+	}
+	if info, _ := q.GetJobByID("job1"); info.State != utils.Delivered {
+		t.Errorf("Expected job1 to be delivered, got %s", info.State)
+	}
+	if err := q.AddJob(uniqueJob("job3", "k")); err != nil {
+		t.Errorf("Expected the key to be free once its job was delivered, got %v", err)
+	}
+}
+
+func TestDuplicateBatchIsRejected(t *testing.T) {
+	q := NewQueue()
+	err := q.AddJobs([]utils.Job{uniqueJob("job1", "k"), uniqueJob("job2", "k")})
+	if !errors.Is(err, ErrDuplicateJob) {
+		t.Fatalf("Expected ErrDuplicateJob, got %v", err)
+	}
+	if err := q.AddJob(uniqueJob("job3", "k")); err != nil {
+		t.Errorf("Expected the failed batch to release its keys, got %v", err)
+	}
+}
+
+func TestUniquenessWindow(t *testing.T) {
+	q := NewQueue(WithUniqueness(30*time.Millisecond, RejectDuplicates))
+	q.AddJob(uniqueJob("job1", "k"))
+	l, _ := q.Lease(time.Minute)
+	q.Ack(l)
+
+	if err := q.AddJob(uniqueJob("job2", "k")); !errors.Is(err, ErrDuplicateJob) {
+		t.Errorf("Expected the key to stay taken within the window, got %v", err)
+	}
+	time.Sleep(40 * time.Millisecond)
+	if err := q.AddJob(uniqueJob("job2", "k")); err != nil {
+		t.Errorf("Expected the key to be free after the window, got %v", err)
+	}
+}
+
+func TestMergeDuplicates(t *testing.T) {
+	q := NewQueue(WithUniqueness(0, MergeDuplicates))
+	q.AddJob(uniqueJob("job1", "k"))
+
+	if err := q.AddJob(uniqueJob("job2", "k")); err != nil {
+		t.Fatalf("Expected a merged job to be accepted, got %v", err)
+	}
+	jobs, _ := q.GetJobs(10)
+	if len(jobs) != 1 || jobs[0].ID != "job1" {
+		t.Errorf("Expected only job1 to be queued, got %v", jobs)
+	}
+	if _, err := q.GetJobByID("job2"); !errors.Is(err, ErrUnknownJob) {
+		t.Errorf("Expected the merged job to be unknown, got %v", err)
+	}
+}
+
+func TestScheduledJobKeepsItsKey(t *testing.T) {
+	q := NewQueue()
+	job := uniqueJob("job1", "k")
+	q.Schedule(job, time.Now())
+
+	if err := q.AddJob(uniqueJob("job2", "k")); !errors.Is(err, ErrDuplicateJob) {
+		t.Errorf("Expected ErrDuplicateJob for a scheduled key, got %v", err)
+	}
+	if err := q.AddJob(job); err != nil {
+		t.Errorf("Expected the scheduled job itself to be added, got %v", err)
+	}
+}
+
+func TestUniqueKeysSurviveRestart(t *testing.T) {
+	dir := t.TempDir()
+
+	q := NewQueue(WithLog(openTestLog(t, dir)))
+	q.AddJob(uniqueJob("job1", "k"))
+	q.Close()
+
+	q = NewQueue(WithLog(openTestLog(t, dir)))
+	defer q.Close()
+
+	if err := q.AddJob(uniqueJob("job2", "k")); !errors.Is(err, ErrDuplicateJob) {
+		t.Errorf("Expected ErrDuplicateJob after a restart, got %v", err)
+	}
+}
+
+func TestStorageHoldsKeysOfOtherQueues(t *testing.T) {
+	m := NewMemoryStorage()
+	q1 := NewQueue(WithStorage(m))
+	q2 := NewQueue(WithStorage(m), WithUniqueness(0, MergeDuplicates))
+
+	q1.AddJob(uniqueJob("job1", "k"))
+	if err := q2.AddJob(uniqueJob("job2", "k")); err != nil {
+		t.Errorf("Expected the duplicate to be merged, got %v", err)
+	}
+	if n, _ := m.Len(utils.DefaultQueue, utils.High); n != 1 {
+		t.Errorf("Expected only job1 to be stored, got %d jobs", n)
+	}
+}
@@ -0,0 +1,116 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/Avik-creator/utils"
+)
+
+// WithPollInterval makes blocked dequeues re-check the storage at least every
+// d. It is only needed when another process writes to the same storage, as
+// jobs added through this JobQueue wake waiters straight away.
+func WithPollInterval(d time.Duration) Option {
+	return func(q *JobQueue) {
+		q.pollInterval = d
+	}
+}
+
+// Dequeue is GetJob that blocks until a job is available or ctx is done.
+func (q *JobQueue) Dequeue(ctx context.Context, queues ...string) (utils.Job, error) {
+	var job utils.Job
+	err := q.block(ctx, ErrEmpty, func() error {
+		var err error
+		job, err = q.getJob(queues)
+		return err
+	})
+	return job, err
+}
+
+// DequeueLease is Lease that blocks until a job is available or ctx is done.
+func (q *JobQueue) DequeueLease(ctx context.Context, timeout time.Duration, queues ...string) (*Lease, error) {
+	var l *Lease
+	err := q.block(ctx, ErrEmpty, func() error {
+		var err error
+		l, err = q.lease(timeout, queues)
+		return err
+	})
+	return l, err
+}
+
+// block calls try with q.mu held until it returns something other than
+// again, sleeping in between until the queue is notified, an in-flight job is
+// due back in its bucket, the poll interval passes or ctx is done.
+func (q *JobQueue) block(ctx context.Context, again error, try func() error) error {
+	for {
+		q.mu.Lock()
+		err := try()
+		if !errors.Is(err, again) {
+			q.mu.Unlock()
+			return err
+		}
+		ready := q.ready
+		wait := q.nextWake()
+		q.mu.Unlock()
+
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if wait > 0 {
+			timer = time.NewTimer(wait)
+			timeout = timer.C
+		}
+
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-ready:
+			err = nil
+		case <-timeout:
+			err = nil
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// nextWake returns how long a blocked dequeue may sleep before it has to look
+// at the storage again, or zero to sleep until notified. It is called with
+// q.mu held.
+func (q *JobQueue) nextWake() time.Duration {
+	wait := q.pollInterval
+
+	next, ok, err := q.storage.NextDeadline()
+	if err != nil {
+		log.Printf("queue: failed to find the next lease deadline: %v", err)
+		return wait
+	}
+	if !ok {
+		return wait
+	}
+	until := max(time.Until(next), time.Millisecond)
+	if wait == 0 || until < wait {
+		wait = until
+	}
+	return wait
+}
+
+// notify wakes every blocked dequeue and AddJob. It is called with q.mu
+// held.
+func (q *JobQueue) notify() {
+	close(q.ready)
+	q.ready = make(chan struct{})
+}
+
+// freed wakes AddJob calls waiting for space after a job has left its queue.
+// It is called with q.mu held.
+func (q *JobQueue) freed() {
+	if len(q.limits) > 0 {
+		q.notify()
+	}
+}
@@ -0,0 +1,89 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Avik-creator/utils"
+)
+
+func TestDequeueWokenByAddJob(t *testing.T) {
+	q := NewQueue()
+
+	got := make(chan utils.Job)
+	go func() {
+		job, err := q.Dequeue(context.Background())
+		if err != nil {
+			t.Errorf("Dequeue failed: %v", err)
+		}
+		got <- job
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	q.AddJob(utils.Job{ID: "job1", Priority: utils.Low, CreatedAt: time.Now()})
+
+	select {
+	case job := <-got:
+		if job.ID != "job1" {
+			t.Errorf("Expected job1, got %s", job.ID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Dequeue was not woken by AddJob")
+	}
+}
+
+func TestDequeueContextCancelled(t *testing.T) {
+	q := NewQueue()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if _, err := q.Dequeue(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestDequeueLeaseWokenByExpiredLease(t *testing.T) {
+	q := NewQueue()
+	q.AddJob(utils.Job{ID: "job1", Priority: utils.High, CreatedAt: time.Now()})
+
+	if _, err := q.Lease(30 * time.Millisecond); err != nil {
+		t.Fatalf("Lease failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	l, err := q.DequeueLease(ctx, time.Minute)
+	if err != nil {
+		t.Fatalf("DequeueLease failed: %v", err)
+	}
+	if l.Job.ID != "job1" {
+		t.Errorf("Expected job1 after its first lease expired, got %s", l.Job.ID)
+	}
+}
+
+func TestDequeuePollInterval(t *testing.T) {
+	q := NewQueue(WithPollInterval(10 * time.Millisecond))
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		// Written behind the queue's back, as another process would.
+		q.mu.Lock()
+		q.storage.Enqueue(utils.Job{ID: "job1", Queue: utils.DefaultQueue, Priority: utils.Medium, CreatedAt: time.Now()})
+		q.mu.Unlock()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	job, err := q.Dequeue(ctx)
+	if err != nil {
+		t.Fatalf("Dequeue failed: %v", err)
+	}
+	if job.ID != "job1" {
+		t.Errorf("Expected job1, got %s", job.ID)
+	}
+}
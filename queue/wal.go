@@ -0,0 +1,384 @@
+package queue
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Avik-creator/utils"
+)
+
+const (
+	opAdd           = "add"
+	opAddBatch      = "add_batch"
+	opGet           = "get"
+	opRemove        = "remove"
+	opDeadLetter    = "dead_letter"
+	opGetDeadLetter = "get_dead_letter"
+	opSchedule      = "schedule"
+	opLease         = "lease"
+	opHold          = "hold"
+	opAck           = "ack"
+	opResult        = "result"
+	opPause         = "pause"
+	opResume        = "resume"
+)
+
+const (
+	segmentPrefix  = "wal-"
+	segmentSuffix  = ".log"
+	snapshotPrefix = "snapshot-"
+	snapshotSuffix = ".json"
+	lockName       = "LOCK"
+)
+
+// ErrLogLocked is returned by OpenLog for a directory another process has
+// open.
+var ErrLogLocked = errors.New("log directory is locked by another process")
+
+type record struct {
+	Op     string      `json:"op"`
+	Job    utils.Job   `json:"job"`
+	Jobs   []utils.Job `json:"jobs,omitempty"`
+	At     time.Time   `json:"at,omitzero"`
+	Result *Result     `json:"result,omitempty"`
+	Pause  *Pause      `json:"pause,omitempty"`
+}
+
+// snapshot is the queue state as of the end of log segment Segment.
+type snapshot struct {
+	Segment    uint64         `json:"segment"`
+	Queue      []utils.Job    `json:"queue"`
+	DeadLetter []utils.Job    `json:"dead_letter"`
+	InFlight   []HeldJob      `json:"in_flight"`
+	Scheduled  []ScheduledJob `json:"scheduled"`
+	Results    []Result       `json:"results,omitempty"`
+	Pauses     []Pause        `json:"pauses,omitempty"`
+}
+
+// Log is an append-only write-ahead log of queue operations, kept as a
+// directory of numbered segments plus the latest snapshot. Every record is
+// framed as a 4-byte length, a 4-byte CRC-32 and a JSON body, and the active
+// segment is fsync'd after each append.
+//
+// A log belongs to a single process: the queue state lives in that process's
+// memory, so OpenLog locks the directory and fails with ErrLogLocked while
+// another process has it open.
+type Log struct {
+	dir      string
+	lock     *os.File
+	file     *os.File
+	segment  uint64
+	size     int64
+	snapshot *snapshot
+	pending  []record
+}
+
+// OpenLog opens or creates the log in dir. It loads the latest snapshot and
+// reads back the records of every later segment so that NewQueue can replay
+// them. A torn or corrupt record at the end of a segment, as left by a crash
+// mid-append, is truncated away.
+func OpenLog(dir string) (l *Log, err error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	lock, err := lockDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			lock.Close()
+		}
+	}()
+
+	snapshots, err := listSeqs(dir, snapshotPrefix, snapshotSuffix)
+	if err != nil {
+		return nil, err
+	}
+	segments, err := listSeqs(dir, segmentPrefix, segmentSuffix)
+	if err != nil {
+		return nil, err
+	}
+
+	l = &Log{dir: dir, lock: lock}
+	if len(snapshots) > 0 {
+		snap, err := readSnapshot(l.snapshotPath(snapshots[len(snapshots)-1]))
+		if err != nil {
+			return nil, err
+		}
+		l.snapshot = snap
+		l.segment = snap.Segment
+	}
+
+	var active uint64
+	for _, seq := range segments {
+		if seq <= l.segment {
+			continue
+		}
+		records, err := l.readSegment(seq)
+		if err != nil {
+			return nil, err
+		}
+		l.pending = append(l.pending, records...)
+		active = seq
+	}
+
+	if active == 0 {
+		active = l.segment + 1
+	}
+	if err := l.openSegment(active); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func listSeqs(dir, prefix, suffix string) ([]uint64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var seqs []uint64
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(name, prefix), suffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	return seqs, nil
+}
+
+func (l *Log) segmentPath(seq uint64) string {
+	return filepath.Join(l.dir, fmt.Sprintf("%s%020d%s", segmentPrefix, seq, segmentSuffix))
+}
+
+func (l *Log) snapshotPath(seq uint64) string {
+	return filepath.Join(l.dir, fmt.Sprintf("%s%020d%s", snapshotPrefix, seq, snapshotSuffix))
+}
+
+func (l *Log) readSegment(seq uint64) ([]record, error) {
+	path := l.segmentPath(seq)
+	f, err := os.OpenFile(path, os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	records, valid, err := readRecords(f)
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if valid < size {
+		log.Printf("queue: truncating %d trailing bytes of corrupt log %s", size-valid, path)
+		if err := f.Truncate(valid); err != nil {
+			return nil, err
+		}
+		if err := f.Sync(); err != nil {
+			return nil, err
+		}
+	}
+	return records, nil
+}
+
+func (l *Log) openSegment(seq uint64) error {
+	f, err := os.OpenFile(l.segmentPath(seq), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	l.file = f
+	l.segment = seq
+	l.size = size
+	return nil
+}
+
+func readRecords(f *os.File) ([]record, int64, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, 0, err
+	}
+
+	r := bufio.NewReader(f)
+	var records []record
+	var valid int64
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return records, valid, nil
+			}
+			return nil, 0, err
+		}
+
+		size := binary.BigEndian.Uint32(header[0:4])
+		sum := binary.BigEndian.Uint32(header[4:8])
+		body := make([]byte, size)
+		if _, err := io.ReadFull(r, body); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return records, valid, nil
+			}
+			return nil, 0, err
+		}
+		if crc32.ChecksumIEEE(body) != sum {
+			return records, valid, nil
+		}
+
+		var rec record
+		if err := json.Unmarshal(body, &rec); err != nil {
+			return records, valid, nil
+		}
+		records = append(records, rec)
+		valid += int64(len(header)) + int64(size)
+	}
+}
+
+func readSnapshot(path string) (*snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("decode snapshot %s: %w", path, err)
+	}
+	return &snap, nil
+}
+
+func (l *Log) append(rec record) error {
+	body, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("encode log record: %w", err)
+	}
+
+	frame := make([]byte, 8+len(body))
+	binary.BigEndian.PutUint32(frame[0:4], uint32(len(body)))
+	binary.BigEndian.PutUint32(frame[4:8], crc32.ChecksumIEEE(body))
+	copy(frame[8:], body)
+
+	if _, err := l.file.Write(frame); err != nil {
+		// Drop the partial frame so later appends stay readable.
+		l.file.Truncate(l.size)
+		l.file.Seek(l.size, io.SeekStart)
+		return err
+	}
+	if err := l.file.Sync(); err != nil {
+		return err
+	}
+	l.size += int64(len(frame))
+	return nil
+}
+
+// compact starts a new segment, writes snap as covering everything up to the
+// end of the previous one and then deletes the segments and snapshots it
+// supersedes.
+func (l *Log) compact(snap snapshot) error {
+	covered := l.segment
+	if err := l.file.Close(); err != nil {
+		return err
+	}
+	if err := l.openSegment(covered + 1); err != nil {
+		return err
+	}
+
+	snap.Segment = covered
+	if err := l.writeSnapshot(snap); err != nil {
+		return err
+	}
+
+	segments, err := listSeqs(l.dir, segmentPrefix, segmentSuffix)
+	if err != nil {
+		return err
+	}
+	for _, seq := range segments {
+		if seq <= covered {
+			if err := os.Remove(l.segmentPath(seq)); err != nil {
+				return err
+			}
+		}
+	}
+	snapshots, err := listSeqs(l.dir, snapshotPrefix, snapshotSuffix)
+	if err != nil {
+		return err
+	}
+	for _, seq := range snapshots {
+		if seq < covered {
+			if err := os.Remove(l.snapshotPath(seq)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (l *Log) writeSnapshot(snap snapshot) error {
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return fmt.Errorf("encode snapshot: %w", err)
+	}
+
+	path := l.snapshotPath(snap.Segment)
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	return syncDir(l.dir)
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+func (l *Log) Close() error {
+	err := l.file.Close()
+	if lerr := l.lock.Close(); err == nil {
+		err = lerr
+	}
+	return err
+}
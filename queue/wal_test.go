@@ -0,0 +1,298 @@
+package queue
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Avik-creator/utils"
+)
+
+func openTestLog(t *testing.T, dir string) *Log {
+	t.Helper()
+	l, err := OpenLog(dir)
+	if err != nil {
+		t.Fatalf("OpenLog failed: %v", err)
+	}
+	return l
+}
+
+func TestLogReplay(t *testing.T) {
+	dir := t.TempDir()
+
+	q := NewQueue(WithLog(openTestLog(t, dir)))
+	job1 := utils.Job{ID: "job1", Priority: utils.High, CreatedAt: time.Now()}
+	job2 := utils.Job{ID: "job2", Priority: utils.Medium, CreatedAt: time.Now()}
+	job3 := utils.Job{ID: "job3", Priority: utils.Low, CreatedAt: time.Now()}
+	job4 := utils.Job{ID: "job4", Priority: utils.Low, CreatedAt: time.Now()}
+
+	q.AddJob(job1)
+	q.AddJob(job2)
+	q.AddJob(job3)
+	q.AddJob(job4)
+	if _, err := q.GetJob(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	q.MoveJobToDeadLetterQueue(job2)
+	q.RemoveJobFromQueue(job4)
+	if err := q.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	q = NewQueue(WithLog(openTestLog(t, dir)))
+	defer q.Close()
+
+	highJobs, mediumJobs, lowJobs, _ := q.GetAllJobs()
+	if len(highJobs) != 0 || len(mediumJobs) != 0 {
+		t.Errorf("Expected high and medium queues to be empty, got %d and %d", len(highJobs), len(mediumJobs))
+	}
+	if len(lowJobs) != 1 || lowJobs[0].ID != "job3" {
+		t.Errorf("Expected only job3 in low priority queue, got %v", lowJobs)
+	}
+
+	_, deadMedium, _, _ := q.GetAllDeadLetterJobs()
+	if len(deadMedium) != 1 || deadMedium[0].ID != "job2" {
+		t.Errorf("Expected job2 in medium dead letter queue, got %v", deadMedium)
+	}
+}
+
+func TestLogIsLockedWhileOpen(t *testing.T) {
+	dir := t.TempDir()
+	l := openTestLog(t, dir)
+
+	if _, err := OpenLog(dir); !errors.Is(err, ErrLogLocked) {
+		t.Fatalf("Expected ErrLogLocked opening a log that is already open, got %v", err)
+	}
+	l.Close()
+
+	l, err := OpenLog(dir)
+	if err != nil {
+		t.Fatalf("Expected the log to open once closed, got %v", err)
+	}
+	l.Close()
+}
+
+func TestLogWritesBatchAsOneRecord(t *testing.T) {
+	dir := t.TempDir()
+
+	l := openTestLog(t, dir)
+	q := NewQueue(WithLog(l))
+	now := time.Now()
+	err := q.AddJobs([]utils.Job{
+		{ID: "job1", Priority: utils.High, CreatedAt: now},
+		{ID: "job2", Priority: utils.High, CreatedAt: now},
+		{ID: "job3", Priority: utils.Low, CreatedAt: now},
+	})
+	if err != nil {
+		t.Fatalf("AddJobs failed: %v", err)
+	}
+	q.Close()
+
+	records, err := l.readSegment(l.segment)
+	if err != nil {
+		t.Fatalf("readSegment failed: %v", err)
+	}
+	if len(records) != 1 || records[0].Op != opAddBatch || len(records[0].Jobs) != 3 {
+		t.Fatalf("Expected a single record for the batch, got %+v", records)
+	}
+
+	q = NewQueue(WithLog(openTestLog(t, dir)))
+	defer q.Close()
+	highJobs, _, lowJobs, _ := q.GetAllJobs()
+	if len(highJobs) != 2 || len(lowJobs) != 1 {
+		t.Errorf("Expected the batch to be replayed, got high=%v low=%v", highJobs, lowJobs)
+	}
+}
+
+func TestLogReplayGetDeadLetterJob(t *testing.T) {
+	dir := t.TempDir()
+
+	q := NewQueue(WithLog(openTestLog(t, dir)))
+	q.MoveJobToDeadLetterQueue(utils.Job{ID: "job1", Priority: utils.High})
+	q.MoveJobToDeadLetterQueue(utils.Job{ID: "job2", Priority: utils.High})
+	if _, err := q.GetDeadLetterJob(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	q.Close()
+
+	q = NewQueue(WithLog(openTestLog(t, dir)))
+	defer q.Close()
+
+	deadHigh, _, _, _ := q.GetAllDeadLetterJobs()
+	if len(deadHigh) != 1 || deadHigh[0].ID != "job2" {
+		t.Errorf("Expected only job2 in dead letter queue, got %v", deadHigh)
+	}
+}
+
+func TestLogRetryJobSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+
+	q := NewQueue(WithLog(openTestLog(t, dir)))
+	q.AddJob(utils.Job{ID: "job1", Priority: utils.High})
+	job, err := q.GetJob()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	job.RetryCount++
+	q.RetryJob(job, 50*time.Millisecond)
+	q.Close()
+
+	q = NewQueue(WithLog(openTestLog(t, dir)))
+	defer q.Close()
+
+	if _, err := q.GetJob(); err == nil {
+		t.Fatal("Expected retrying job to stay hidden until its delay has passed")
+	}
+	time.Sleep(60 * time.Millisecond)
+
+	got, err := q.GetJob()
+	if err != nil {
+		t.Fatalf("Expected retrying job to be restored, got error: %v", err)
+	}
+	if got.ID != "job1" || got.RetryCount != 1 {
+		t.Errorf("Expected job1 with RetryCount 1, got %s with RetryCount %d", got.ID, got.RetryCount)
+	}
+}
+
+func TestLogTruncatesTornRecord(t *testing.T) {
+	dir := t.TempDir()
+
+	q := NewQueue(WithLog(openTestLog(t, dir)))
+	q.AddJob(utils.Job{ID: "job1", Priority: utils.High})
+	q.Close()
+
+	// Simulate a crash half-way through writing a second record.
+	segments, _ := filepath.Glob(filepath.Join(dir, "wal-*.log"))
+	if len(segments) != 1 {
+		t.Fatalf("Expected a single log segment, got %v", segments)
+	}
+	f, err := os.OpenFile(segments[0], os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{0, 0, 0, 42, 1, 2, 3})
+	f.Close()
+
+	q = NewQueue(WithLog(openTestLog(t, dir)))
+	q.AddJob(utils.Job{ID: "job2", Priority: utils.High})
+	q.Close()
+
+	q = NewQueue(WithLog(openTestLog(t, dir)))
+	defer q.Close()
+
+	highJobs, _, _, _ := q.GetAllJobs()
+	if len(highJobs) != 2 || highJobs[0].ID != "job1" || highJobs[1].ID != "job2" {
+		t.Errorf("Expected job1 and job2 after truncating torn record, got %v", highJobs)
+	}
+}
+
+func TestSnapshotCompactsLog(t *testing.T) {
+	dir := t.TempDir()
+
+	q := NewQueue(WithLog(openTestLog(t, dir)))
+	q.AddJob(utils.Job{ID: "job1", Priority: utils.High})
+	q.AddJob(utils.Job{ID: "job2", Priority: utils.Low})
+	q.AddJob(utils.Job{ID: "job3", Priority: utils.Low})
+	q.MoveJobToDeadLetterQueue(utils.Job{ID: "job2", Priority: utils.Low})
+	at := time.Now().Add(time.Hour).Round(0)
+	if err := q.Schedule(utils.Job{ID: "job4", Priority: utils.Medium}, at); err != nil {
+		t.Fatalf("Schedule failed: %v", err)
+	}
+
+	if err := q.Snapshot(); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+
+	segments, _ := filepath.Glob(filepath.Join(dir, "wal-*.log"))
+	snapshots, _ := filepath.Glob(filepath.Join(dir, "snapshot-*.json"))
+	if len(segments) != 1 || len(snapshots) != 1 {
+		t.Fatalf("Expected one segment and one snapshot after compaction, got %v and %v", segments, snapshots)
+	}
+
+	// Operations after the snapshot land in the new segment.
+	q.AddJob(utils.Job{ID: "job5", Priority: utils.High})
+	q.Close()
+
+	q = NewQueue(WithLog(openTestLog(t, dir)))
+	defer q.Close()
+
+	highJobs, _, lowJobs, _ := q.GetAllJobs()
+	if len(highJobs) != 2 || highJobs[0].ID != "job1" || highJobs[1].ID != "job5" {
+		t.Errorf("Expected job1 and job5 in high priority queue, got %v", highJobs)
+	}
+	if len(lowJobs) != 1 || lowJobs[0].ID != "job3" {
+		t.Errorf("Expected job3 in low priority queue, got %v", lowJobs)
+	}
+
+	_, _, deadLow, _ := q.GetAllDeadLetterJobs()
+	if len(deadLow) != 1 || deadLow[0].ID != "job2" {
+		t.Errorf("Expected job2 in low priority dead letter queue, got %v", deadLow)
+	}
+
+	scheduled := q.ScheduledJobs()
+	if len(scheduled) != 1 || scheduled[0].Job.ID != "job4" || !scheduled[0].ScheduleTime.Equal(at) {
+		t.Errorf("Expected job4 to be scheduled at %v, got %v", at, scheduled)
+	}
+}
+
+func TestScheduledJobClearedWhenAdded(t *testing.T) {
+	dir := t.TempDir()
+
+	q := NewQueue(WithLog(openTestLog(t, dir)))
+	job := utils.Job{ID: "job1", Priority: utils.High}
+	q.Schedule(job, time.Now())
+	q.AddJob(job)
+	q.Close()
+
+	q = NewQueue(WithLog(openTestLog(t, dir)))
+	defer q.Close()
+
+	if scheduled := q.ScheduledJobs(); len(scheduled) != 0 {
+		t.Errorf("Expected no scheduled jobs once the job was added, got %v", scheduled)
+	}
+	highJobs, _, _, _ := q.GetAllJobs()
+	if len(highJobs) != 1 {
+		t.Errorf("Expected 1 high priority job, got %d", len(highJobs))
+	}
+}
+
+func TestSnapshotInterval(t *testing.T) {
+	dir := t.TempDir()
+
+	q := NewQueue(WithLog(openTestLog(t, dir)), WithSnapshotInterval(10*time.Millisecond))
+	defer q.Close()
+	q.AddJob(utils.Job{ID: "job1", Priority: utils.High})
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if snapshots, _ := filepath.Glob(filepath.Join(dir, "snapshot-*.json")); len(snapshots) > 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("Expected a snapshot to be written periodically")
+}
+
+func TestSnapshotKeepsNamedQueues(t *testing.T) {
+	dir := t.TempDir()
+
+	q := NewQueue(WithLog(openTestLog(t, dir)))
+	q.AddJob(utils.Job{ID: "job1", Queue: "emails", Priority: utils.High})
+	q.MoveJobToDeadLetterQueue(utils.Job{ID: "job2", Queue: "reports", Priority: utils.Low})
+	if err := q.Snapshot(); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	q.Close()
+
+	q = NewQueue(WithLog(openTestLog(t, dir)))
+	defer q.Close()
+
+	if highJobs, _, _, _ := q.GetAllJobs("emails"); len(highJobs) != 1 || highJobs[0].ID != "job1" {
+		t.Errorf("Expected job1 in emails, got %v", highJobs)
+	}
+	if _, _, deadLow, _ := q.GetAllDeadLetterJobs("reports"); len(deadLow) != 1 || deadLow[0].ID != "job2" {
+		t.Errorf("Expected job2 in the reports dead letter queue, got %v", deadLow)
+	}
+}
@@ -1,20 +1,25 @@
 package scheduler
 
 import (
-	"container/heap"
-	"sync"
+	"errors"
+	"log"
 	"time"
 
 	"github.com/Avik-creator/queue"
 	"github.com/Avik-creator/utils"
 )
 
+// Deprecated: schedules are kept by the queue's storage; see
+// queue.ScheduledJob.
 type ScheduleJob struct {
 	Job          utils.Job
 	ScheduleTime time.Time
 	index        int
 }
 
+// JobHeap orders ScheduleJobs soonest first.
+//
+// Deprecated: the Scheduler no longer keeps a heap of its own.
 type JobHeap []*ScheduleJob
 
 func (h JobHeap) Len() int           { return len(h) }
@@ -37,45 +42,40 @@ func (h *JobHeap) Pop() interface{} {
 	return job
 }
 
+// Scheduler adds scheduled jobs to a queue once they are due. The schedules
+// themselves are kept by the queue's storage, so every Scheduler sharing that
+// storage sees them, and each due job is added by exactly one of them.
 type Scheduler struct {
-	mu    sync.Mutex
-	heap  JobHeap
 	queue *queue.JobQueue
 }
 
 func NewScheduler(q *queue.JobQueue) *Scheduler {
-	h := make(JobHeap, 0)
-	heap.Init(&h)
-
-	s := &Scheduler{heap: h, queue: q}
+	s := &Scheduler{queue: q}
 	go s.poller()
 	return s
 }
 
-func (s *Scheduler) Scheduler(j utils.Job, delay time.Duration) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	scheduled := &ScheduleJob{
-		Job:          j,
-		ScheduleTime: time.Now().Add(delay),
-	}
-
-	heap.Push(&s.heap, scheduled)
+// Scheduler adds j to the queue once delay has passed. It fails with
+// ErrInvalidPriority or ErrDuplicateJob when the queue refuses the job, or
+// when the schedule cannot be stored; a job the queue merged into another one
+// is not scheduled at all.
+func (s *Scheduler) Scheduler(j utils.Job, delay time.Duration) error {
+	return s.queue.Schedule(j, time.Now().Add(delay))
 }
 
 func (s *Scheduler) poller() {
 	for {
-		s.mu.Lock()
-		if s.heap.Len() > 0 {
-			next := s.heap[0]
-			if time.Now().After(next.ScheduleTime) {
-				heap.Pop(&s.heap)
-				s.queue.AddJob(next.Job)
+		due, err := s.queue.TakeDueJobs(time.Now())
+		if err != nil {
+			log.Printf("scheduler: failed to take due jobs: %v", err)
+		}
+		for _, sj := range due {
+			if err := s.queue.AddJob(sj.Job); errors.Is(err, queue.ErrJobExpired) {
+				log.Printf("scheduler: job %s expired before it was due", sj.Job.ID)
+			} else if err != nil {
+				log.Printf("scheduler: failed to add job %s: %v", sj.Job.ID, err)
 			}
 		}
-
-		s.mu.Unlock()
 		time.Sleep(500 * time.Millisecond)
 	}
 }
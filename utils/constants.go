@@ -5,13 +5,36 @@ import "time"
 type Job struct {
 	ID         string            `json:"id"`
 	Type       string            `json:"type"`
+	Queue      string            `json:"queue,omitempty"`
 	Payload    map[string]string `json:"payload"`
 	Priority   Priority          `json:"priority"`
 	RetryCount int               `json:"retry_count"`
 	MaxRetries int               `json:"max_retries"`
 	CreatedAt  time.Time         `json:"created_at"`
+	// UniqueKey, when set, keeps other jobs with the same key out of the
+	// queue while this one is pending or running.
+	UniqueKey string `json:"unique_key,omitempty"`
+	// ExpiresAt, when set, is the time after which the job is no longer
+	// worth running and is never handed to a worker.
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+	// DeadReason says why the job was moved to the dead-letter queue.
+	DeadReason string `json:"dead_reason,omitempty"`
+	// Timeout, when set, is how long one attempt at the job may run before
+	// it counts as failed. Zero means the default of the job's type.
+	Timeout time.Duration `json:"timeout,omitempty"`
 }
 
+// Expired reports whether j has an expiry time that is not after now.
+func (j Job) Expired(now time.Time) bool {
+	return !j.ExpiresAt.IsZero() && !now.Before(j.ExpiresAt)
+}
+
+// DefaultQueue is the queue a job goes to when its Queue is empty.
+const DefaultQueue = "default"
+
+// Priority orders jobs within a queue: lower values are served first. Any
+// value from MinPriority to MaxPriority is valid; High, Medium and Low are
+// the common ones.
 type Priority int
 
 const (
@@ -19,3 +42,12 @@ const (
 	Medium Priority = 2
 	Low    Priority = 3
 )
+
+const (
+	MinPriority Priority = 1
+	MaxPriority Priority = 1000
+)
+
+func (p Priority) Valid() bool {
+	return p >= MinPriority && p <= MaxPriority
+}
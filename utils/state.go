@@ -0,0 +1,47 @@
+package utils
+
+// JobState is where a job is in its life cycle.
+type JobState string
+
+const (
+	// Scheduled jobs are waiting for the scheduler to add them.
+	Scheduled JobState = "scheduled"
+	// Queued jobs are waiting in their priority bucket.
+	Queued JobState = "queued"
+	// Running jobs have been leased to a worker.
+	Running JobState = "running"
+	// Delivered jobs were handed out without a lease, so the queue does not
+	// learn how they end. Like Dead, it is final until the job is queued
+	// again.
+	Delivered JobState = "delivered"
+	// Retrying jobs failed and are held until their retry delay has passed.
+	Retrying JobState = "retrying"
+	// Succeeded jobs were acknowledged by their worker. It is a final state.
+	Succeeded JobState = "succeeded"
+	// Dead jobs were moved to the dead-letter queue. They can be queued
+	// again.
+	Dead JobState = "dead"
+)
+
+var transitions = map[JobState][]JobState{
+	Scheduled: {Scheduled, Queued, Dead},
+	Queued:    {Queued, Running, Delivered, Dead},
+	Running:   {Queued, Retrying, Succeeded, Dead},
+	Delivered: {Queued, Retrying, Dead},
+	Retrying:  {Queued, Retrying, Dead},
+	Dead:      {Scheduled, Queued, Dead},
+}
+
+// CanBecome reports whether a job in state s may move to next. The empty
+// state, a job not seen before, may start out in any state.
+func (s JobState) CanBecome(next JobState) bool {
+	if s == "" {
+		return true
+	}
+	for _, t := range transitions[s] {
+		if t == next {
+			return true
+		}
+	}
+	return false
+}
@@ -1,5 +1,10 @@
 package utils
 
+// RemoveJob returns jobs without the job with job's ID, reusing the backing
+// array of jobs.
+//
+// Deprecated: the queue no longer keeps jobs in slices; use
+// slices.DeleteFunc instead.
 func RemoveJob(jobs []Job, job Job) []Job {
 	for i, j := range jobs {
 		if j.ID == job.ID {
@@ -0,0 +1,164 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Avik-creator/queue"
+	"github.com/Avik-creator/utils"
+)
+
+// Defaults for the zero fields of an Autoscaler.
+const (
+	DefaultScaleInterval     = 5 * time.Second
+	DefaultJobsPerWorker     = 10
+	DefaultScaleUpCooldown   = 15 * time.Second
+	DefaultScaleDownCooldown = time.Minute
+	DefaultBusyUtilisation   = 0.8
+	DefaultIdleUtilisation   = 0.3
+)
+
+// Autoscaler resizes a Pool between Min and Max workers as the backlog of
+// the queues it works on grows and shrinks.
+//
+// Every Interval it grows the pool when jobs are waiting and the workers are
+// busy, there are more than JobsPerWorker waiting jobs per worker, or a
+// priority's oldest job has waited longer than its MaxWait. It grows by one
+// worker, or straight to one per JobsPerWorker waiting jobs if that is more.
+// It shrinks the pool when nothing is waiting and the workers are mostly
+// idle, by half its idle workers at a time; workers removed this way finish
+// their running job first.
+//
+// A resize is followed by ScaleUpCooldown before the pool grows again and
+// ScaleDownCooldown before it shrinks, so that a short burst does not make
+// it flap.
+type Autoscaler struct {
+	Pool     *Pool
+	Min, Max int
+	// Interval is how often the backlog is checked. Zero means
+	// DefaultScaleInterval.
+	Interval time.Duration
+	// JobsPerWorker is how many waiting jobs one worker is expected to keep
+	// up with. Zero means DefaultJobsPerWorker.
+	JobsPerWorker int
+	// MaxWait is how long jobs of each priority may wait before the pool
+	// grows. Priorities missing from it are not considered.
+	MaxWait map[utils.Priority]time.Duration
+	// BusyUtilisation and IdleUtilisation are the shares of busy workers
+	// above which the pool counts as busy and below which it counts as
+	// idle. Zero means DefaultBusyUtilisation and DefaultIdleUtilisation.
+	BusyUtilisation float64
+	IdleUtilisation float64
+	// ScaleUpCooldown and ScaleDownCooldown are how long after a resize the
+	// pool is left to settle before growing or shrinking. Zero means the
+	// defaults.
+	ScaleUpCooldown   time.Duration
+	ScaleDownCooldown time.Duration
+
+	lastResize time.Time
+}
+
+// Run resizes the pool until ctx is done. A pool outside Min and Max is
+// brought within them at once.
+func (a *Autoscaler) Run(ctx context.Context) error {
+	if a.Min < 0 || a.Max < 1 || a.Min > a.Max {
+		return fmt.Errorf("invalid autoscaler bounds %d to %d", a.Min, a.Max)
+	}
+	interval := a.Interval
+	if interval == 0 {
+		interval = DefaultScaleInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := a.scale(time.Now()); err != nil {
+			log.Printf("Autoscaler failed : %v\n", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// scale checks the backlog once and resizes the pool if needed.
+func (a *Autoscaler) scale(now time.Time) error {
+	backlog, err := a.Pool.template.Queue.Backlog(a.Pool.template.Queues...)
+	if err != nil {
+		return err
+	}
+	stats := a.Pool.Stats()
+	n := a.size(stats, backlog, now)
+	if n == stats.Workers {
+		return nil
+	}
+	if err := a.Pool.Resize(n); err != nil {
+		return err
+	}
+	a.lastResize = now
+	log.Printf("Autoscaler resized pool from %d to %d worker(s)\n", stats.Workers, n)
+	return nil
+}
+
+// size returns the number of workers the pool should have.
+func (a *Autoscaler) size(stats PoolStats, backlog []queue.Backlog, now time.Time) int {
+	n := stats.Workers
+	if n < a.Min {
+		return a.Min
+	}
+	if n > a.Max {
+		return a.Max
+	}
+
+	perWorker := a.JobsPerWorker
+	if perWorker == 0 {
+		perWorker = DefaultJobsPerWorker
+	}
+	busyAt := a.BusyUtilisation
+	if busyAt == 0 {
+		busyAt = DefaultBusyUtilisation
+	}
+	idleAt := a.IdleUtilisation
+	if idleAt == 0 {
+		idleAt = DefaultIdleUtilisation
+	}
+	upCooldown := a.ScaleUpCooldown
+	if upCooldown == 0 {
+		upCooldown = DefaultScaleUpCooldown
+	}
+	downCooldown := a.ScaleDownCooldown
+	if downCooldown == 0 {
+		downCooldown = DefaultScaleDownCooldown
+	}
+
+	var waiting int
+	overdue := false
+	for _, b := range backlog {
+		waiting += b.Jobs
+		if limit, ok := a.MaxWait[b.Priority]; ok && b.Wait > limit {
+			overdue = true
+		}
+	}
+	utilisation := 1.0
+	if n > 0 {
+		utilisation = float64(stats.Busy) / float64(n)
+	}
+
+	switch {
+	case waiting > 0 && (utilisation >= busyAt || waiting > n*perWorker || overdue):
+		if now.Sub(a.lastResize) < upCooldown {
+			return n
+		}
+		return min(a.Max, max(n+1, (waiting+perWorker-1)/perWorker))
+	case waiting == 0 && utilisation < idleAt && n > a.Min:
+		if now.Sub(a.lastResize) < downCooldown {
+			return n
+		}
+		return max(a.Min, n-(n-stats.Busy+1)/2)
+	}
+	return n
+}
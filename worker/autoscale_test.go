@@ -0,0 +1,94 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Avik-creator/queue"
+	"github.com/Avik-creator/utils"
+)
+
+func TestAutoscaler_Size(t *testing.T) {
+	now := time.Now()
+	a := &Autoscaler{
+		Min:           1,
+		Max:           10,
+		JobsPerWorker: 5,
+		MaxWait:       map[utils.Priority]time.Duration{utils.High: time.Second},
+	}
+	tests := []struct {
+		name    string
+		stats   PoolStats
+		backlog []queue.Backlog
+		want    int
+	}{
+		{"below min", PoolStats{Workers: 0}, nil, 1},
+		{"above max", PoolStats{Workers: 12}, nil, 10},
+		{"busy with jobs waiting", PoolStats{Workers: 2, Busy: 2}, []queue.Backlog{{Priority: utils.Low, Jobs: 1}}, 3},
+		{"deep backlog", PoolStats{Workers: 2}, []queue.Backlog{{Priority: utils.Low, Jobs: 32}}, 7},
+		{"deeper than max", PoolStats{Workers: 2}, []queue.Backlog{{Priority: utils.Low, Jobs: 500}}, 10},
+		{"overdue priority", PoolStats{Workers: 2}, []queue.Backlog{{Priority: utils.High, Jobs: 1, Wait: 2 * time.Second}}, 3},
+		{"waiting without a limit", PoolStats{Workers: 2}, []queue.Backlog{{Priority: utils.Low, Jobs: 1, Wait: time.Hour}}, 2},
+		{"idle", PoolStats{Workers: 8, Busy: 2}, nil, 5},
+		{"idle at min", PoolStats{Workers: 1}, nil, 1},
+		{"mostly busy", PoolStats{Workers: 4, Busy: 2}, nil, 4},
+	}
+	for _, tt := range tests {
+		if got := a.size(tt.stats, tt.backlog, now); got != tt.want {
+			t.Errorf("%s: expected %d workers, got %d", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestAutoscaler_Cooldowns(t *testing.T) {
+	now := time.Now()
+	a := &Autoscaler{Min: 1, Max: 10, ScaleUpCooldown: time.Minute, ScaleDownCooldown: time.Hour, lastResize: now}
+	busy := []queue.Backlog{{Priority: utils.Low, Jobs: 100}}
+
+	if got := a.size(PoolStats{Workers: 2, Busy: 2}, busy, now.Add(30*time.Second)); got != 2 {
+		t.Errorf("Expected no growth within the cooldown, got %d", got)
+	}
+	if got := a.size(PoolStats{Workers: 2, Busy: 2}, busy, now.Add(2*time.Minute)); got != 10 {
+		t.Errorf("Expected growth after the cooldown, got %d", got)
+	}
+	if got := a.size(PoolStats{Workers: 4}, nil, now.Add(2*time.Minute)); got != 4 {
+		t.Errorf("Expected no shrinking within the cooldown, got %d", got)
+	}
+	if got := a.size(PoolStats{Workers: 4}, nil, now.Add(2*time.Hour)); got != 2 {
+		t.Errorf("Expected shrinking after the cooldown, got %d", got)
+	}
+}
+
+func TestAutoscaler_Run(t *testing.T) {
+	q := queue.NewQueue()
+	addSleepJobs(q, 20)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := NewPool(ctx, Worker{Queue: q, Handlers: sleepRegistry(100 * time.Millisecond)}, 1)
+	defer p.Shutdown(context.Background())
+
+	a := &Autoscaler{
+		Pool:              p,
+		Min:               1,
+		Max:               4,
+		Interval:          20 * time.Millisecond,
+		JobsPerWorker:     2,
+		ScaleUpCooldown:   time.Millisecond,
+		ScaleDownCooldown: time.Millisecond,
+	}
+	go a.Run(ctx)
+
+	time.Sleep(100 * time.Millisecond)
+	if n := p.Size(); n != 4 {
+		t.Errorf("Expected the pool to grow to 4 workers, got %d", n)
+	}
+	time.Sleep(time.Second)
+	if n := p.Size(); n != 1 {
+		t.Errorf("Expected the pool to shrink back to 1 worker, got %d", n)
+	}
+	if stats := p.Stats(); stats.Succeeded != 20 {
+		t.Errorf("Expected all 20 jobs to succeed, got %+v", stats)
+	}
+}
@@ -0,0 +1,145 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Avik-creator/utils"
+)
+
+// ErrNoHandler is the error of a job whose Type has no registered handler.
+// Such jobs are not retried; they go straight to the dead-letter queue.
+var ErrNoHandler = errors.New("no handler registered")
+
+// ErrJobTimeout is the error of a job whose handler ran past its timeout.
+// Like any other error it makes the job be retried. A handler that ignores
+// its context keeps running after the timeout, so it may overlap with the
+// retry; handlers that cannot stop on cancellation must be idempotent.
+var ErrJobTimeout = errors.New("job timed out")
+
+// Handler runs jobs of one type. The result it returns is stored against
+// the job's ID; a nil result stores nothing. A returned error makes the job
+// be retried until it runs out of retries. ctx is cancelled once the job's
+// timeout has passed.
+type Handler interface {
+	Handle(ctx context.Context, job utils.Job) ([]byte, error)
+}
+
+// HandlerFunc lets an ordinary function be used as a Handler.
+type HandlerFunc func(ctx context.Context, job utils.Job) ([]byte, error)
+
+func (f HandlerFunc) Handle(ctx context.Context, job utils.Job) ([]byte, error) {
+	return f(ctx, job)
+}
+
+// Registry maps job types to the handlers that run them. It is safe for
+// concurrent use, so handlers can be registered while workers are running.
+type Registry struct {
+	mu       sync.RWMutex
+	handlers map[string]Handler
+	timeouts map[string]time.Duration
+}
+
+func NewRegistry() *Registry {
+	return &Registry{handlers: make(map[string]Handler), timeouts: make(map[string]time.Duration)}
+}
+
+// DefaultRegistry is the registry used by workers without one of their own.
+var DefaultRegistry = NewRegistry()
+
+// Register makes h run the jobs of the given type, replacing any handler
+// registered for it before. It panics if h is nil.
+func (r *Registry) Register(jobType string, h Handler) {
+	if h == nil {
+		panic("worker: nil handler for job type " + jobType)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[jobType] = h
+}
+
+// Handler returns the handler registered for the given type.
+func (r *Registry) Handler(jobType string) (Handler, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	h, ok := r.handlers[jobType]
+	return h, ok
+}
+
+// SetTimeout sets the timeout of jobs of the given type that have no Timeout
+// of their own. Zero removes it, letting such jobs run as long as they take.
+func (r *Registry) SetTimeout(jobType string, d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if d == 0 {
+		delete(r.timeouts, jobType)
+		return
+	}
+	r.timeouts[jobType] = d
+}
+
+// Timeout returns the timeout set for the given type, or zero.
+func (r *Registry) Timeout(jobType string) time.Duration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.timeouts[jobType]
+}
+
+// Register registers h for the given type in DefaultRegistry.
+func Register(jobType string, h Handler) {
+	DefaultRegistry.Register(jobType, h)
+}
+
+// SetTimeout sets the timeout for the given type in DefaultRegistry.
+func SetTimeout(jobType string, d time.Duration) {
+	DefaultRegistry.SetTimeout(jobType, d)
+}
+
+// timeout returns how long an attempt at j may run: its own Timeout, else
+// its type's, else zero for no limit.
+func (r *Registry) timeout(j utils.Job) time.Duration {
+	if j.Timeout != 0 {
+		return j.Timeout
+	}
+	return r.Timeout(j.Type)
+}
+
+// handle runs j through the handler registered for its type. Once j's
+// timeout has passed it cancels ctx and returns ErrJobTimeout without waiting
+// for the handler, so a handler that ignores ctx cannot hold up the worker.
+// Such a handler is left running in the background.
+func (r *Registry) handle(ctx context.Context, j utils.Job) ([]byte, error) {
+	h, ok := r.Handler(j.Type)
+	if !ok {
+		return nil, fmt.Errorf("%w for job type %q", ErrNoHandler, j.Type)
+	}
+	timeout := r.timeout(j)
+	if timeout <= 0 {
+		return h.Handle(ctx, j)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	type outcome struct {
+		result []byte
+		err    error
+	}
+	// Buffered so that an abandoned handler can still finish and exit.
+	done := make(chan outcome, 1)
+	go func() {
+		result, err := h.Handle(ctx, j)
+		done <- outcome{result, err}
+	}()
+
+	select {
+	case o := <-done:
+		if o.err == nil || ctx.Err() == nil {
+			return o.result, o.err
+		}
+	case <-ctx.Done():
+	}
+	return nil, fmt.Errorf("%w after %v", ErrJobTimeout, timeout)
+}
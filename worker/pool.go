@@ -0,0 +1,152 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrPoolClosed is returned by Resize once Shutdown has been called.
+var ErrPoolClosed = errors.New("worker pool is shut down")
+
+// Pool runs a changing number of workers over one queue.
+type Pool struct {
+	ctx      context.Context
+	template Worker
+
+	mu       sync.Mutex
+	workers  []*Worker
+	nextID   int
+	closed   bool
+	stopping sync.WaitGroup
+	draining atomic.Int64
+
+	busy      atomic.Int64
+	succeeded atomic.Uint64
+	failed    atomic.Uint64
+}
+
+// PoolStats is a point-in-time view of a Pool.
+type PoolStats struct {
+	// Workers is the number of workers taking jobs.
+	Workers int `json:"workers"`
+	// Busy is how many workers are running a job.
+	Busy int `json:"busy"`
+	// Draining is how many workers removed by Resize are finishing the job
+	// in hand.
+	Draining int `json:"draining"`
+	// Succeeded and Failed count the jobs run since the pool was created.
+	// A failed job may be retried and counted again.
+	Succeeded uint64 `json:"succeeded"`
+	Failed    uint64 `json:"failed"`
+}
+
+// NewPool starts n workers, each a copy of w with its own ID. They run until
+// ctx is done or the pool is shut down.
+func NewPool(ctx context.Context, w Worker, n int) *Pool {
+	w.ID = 0
+	w.cancel, w.done, w.pool = nil, nil, nil
+	p := &Pool{ctx: ctx, template: w}
+	p.Resize(n)
+	return p
+}
+
+// Resize starts or stops workers until n are running. Stopped workers finish
+// the job in hand and return the rest of their batch to the queue; Resize
+// does not wait for them.
+func (p *Pool) Resize(n int) error {
+	if n < 0 {
+		return fmt.Errorf("negative pool size %d", n)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return ErrPoolClosed
+	}
+
+	for len(p.workers) < n {
+		p.nextID++
+		w := p.template
+		w.ID = p.nextID
+		w.pool = p
+		w.Start(p.ctx)
+		p.workers = append(p.workers, &w)
+	}
+	for len(p.workers) > n {
+		w := p.workers[len(p.workers)-1]
+		p.workers = p.workers[:len(p.workers)-1]
+		p.stopping.Add(1)
+		p.draining.Add(1)
+		go func() {
+			defer p.stopping.Done()
+			defer p.draining.Add(-1)
+			if err := w.Stop(); err != nil {
+				log.Printf("Failed to stop worker : %v\n", err)
+			}
+		}()
+	}
+	return nil
+}
+
+// Size returns the number of workers taking jobs.
+func (p *Pool) Size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.workers)
+}
+
+func (p *Pool) Stats() PoolStats {
+	return PoolStats{
+		Workers:   p.Size(),
+		Busy:      int(p.busy.Load()),
+		Draining:  int(p.draining.Load()),
+		Succeeded: p.succeeded.Load(),
+		Failed:    p.failed.Load(),
+	}
+}
+
+// Shutdown stops every worker and waits for the jobs in hand to finish or
+// ctx to be done, whichever comes first. Jobs still running when ctx is done
+// are left to their leases, which run out and put them back in the queue.
+func (p *Pool) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	workers := p.workers
+	p.workers = nil
+	p.closed = true
+	p.mu.Unlock()
+
+	for _, w := range workers {
+		w.cancel()
+	}
+	done := make(chan struct{})
+	go func() {
+		for _, w := range workers {
+			<-w.done
+		}
+		p.stopping.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%w: %w", ErrDrainTimeout, ctx.Err())
+	}
+}
+
+// count records the outcome of a job run by one of the pool's workers. It
+// does nothing for workers outside a pool.
+func (p *Pool) count(err error) {
+	if p == nil {
+		return
+	}
+	if err != nil {
+		p.failed.Add(1)
+	} else {
+		p.succeeded.Add(1)
+	}
+}
@@ -0,0 +1,93 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Avik-creator/queue"
+	"github.com/Avik-creator/utils"
+)
+
+// sleepRegistry returns a registry whose "sleep" jobs take d.
+func sleepRegistry(d time.Duration) *Registry {
+	r := NewRegistry()
+	r.Register("sleep", HandlerFunc(func(ctx context.Context, j utils.Job) ([]byte, error) {
+		time.Sleep(d)
+		return nil, nil
+	}))
+	return r
+}
+
+func addSleepJobs(q *queue.JobQueue, n int) {
+	for i := 0; i < n; i++ {
+		q.AddJob(utils.Job{ID: fmt.Sprintf("sleep-job-%d", i), Type: "sleep", Priority: utils.High, CreatedAt: time.Now()})
+	}
+}
+
+func TestPool_Resize(t *testing.T) {
+	q := queue.NewQueue()
+	addSleepJobs(q, 12)
+
+	p := NewPool(context.Background(), Worker{Queue: q, Handlers: sleepRegistry(200 * time.Millisecond)}, 1)
+	defer p.Shutdown(context.Background())
+
+	if err := p.Resize(4); err != nil {
+		t.Fatalf("Resize failed: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if stats := p.Stats(); stats.Workers != 4 || stats.Busy != 4 {
+		t.Errorf("Expected 4 busy workers, got %+v", stats)
+	}
+
+	// Shrinking while jobs run must not lose them.
+	if err := p.Resize(2); err != nil {
+		t.Fatalf("Resize failed: %v", err)
+	}
+	if stats := p.Stats(); stats.Workers != 2 || stats.Draining != 2 {
+		t.Errorf("Expected 2 workers and 2 draining, got %+v", stats)
+	}
+
+	time.Sleep(1500 * time.Millisecond)
+	stats := p.Stats()
+	if stats.Succeeded != 12 || stats.Draining != 0 {
+		t.Errorf("Expected all 12 jobs to succeed, got %+v", stats)
+	}
+	if highJobs, _, _, _ := q.GetAllJobs(); len(highJobs) != 0 {
+		t.Errorf("Expected an empty queue, got %v", highJobs)
+	}
+}
+
+func TestPool_Shutdown(t *testing.T) {
+	q := queue.NewQueue()
+	addSleepJobs(q, 3)
+
+	p := NewPool(context.Background(), Worker{Queue: q, Handlers: sleepRegistry(200 * time.Millisecond)}, 3)
+	time.Sleep(50 * time.Millisecond)
+
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Expected the running jobs to be drained, got %v", err)
+	}
+	if stats := p.Stats(); stats.Workers != 0 || stats.Busy != 0 || stats.Succeeded != 3 {
+		t.Errorf("Expected 3 drained jobs and no workers, got %+v", stats)
+	}
+	if err := p.Resize(1); !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("Expected ErrPoolClosed, got %v", err)
+	}
+}
+
+func TestPool_ShutdownDeadline(t *testing.T) {
+	q := queue.NewQueue()
+	addSleepJobs(q, 1)
+
+	p := NewPool(context.Background(), Worker{Queue: q, Handlers: sleepRegistry(time.Second)}, 1)
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := p.Shutdown(ctx); !errors.Is(err, ErrDrainTimeout) {
+		t.Errorf("Expected ErrDrainTimeout, got %v", err)
+	}
+}
@@ -1,6 +1,8 @@
 package worker
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"math"
@@ -8,58 +10,203 @@ import (
 	"time"
 
 	"github.com/Avik-creator/queue"
-	"github.com/Avik-creator/utils"
 )
 
 type Worker struct {
 	ID    int
 	Queue *queue.JobQueue
+	// Queues are the named queues the worker takes jobs from, in turn. None
+	// means utils.DefaultQueue.
+	Queues []string
+	// LeaseTimeout is how long the worker may hold a job before it is handed
+	// to another worker. Zero means queue.DefaultLeaseTimeout.
+	LeaseTimeout time.Duration
+	// BatchSize is how many jobs the worker fetches at once. Jobs of a batch
+	// are processed one after another, each lease being extended just before
+	// its job starts. Zero or one fetches a job at a time.
+	BatchSize int
+	// Handlers runs the jobs by type. Nil means DefaultRegistry.
+	Handlers *Registry
+	// DrainTimeout is how long Stop waits for the job in hand to finish.
+	// Zero means DefaultDrainTimeout.
+	DrainTimeout time.Duration
+
+	cancel context.CancelFunc
+	done   chan struct{}
+	pool   *Pool
 }
 
-func (w *Worker) Start() {
+// DefaultDrainTimeout is how long Stop waits for a running job by default.
+const DefaultDrainTimeout = 30 * time.Second
+
+// timeoutMargin is how far past a job's timeout its lease is extended, so
+// the lease cannot run out while the attempt may still be running.
+const timeoutMargin = 5 * time.Second
+
+// ErrDrainTimeout is returned by Stop when the job in hand is still running
+// once the drain timeout has passed. Its lease is left to run out, after
+// which the job goes back to the queue.
+var ErrDrainTimeout = errors.New("job still running at drain deadline")
+
+// Start runs the worker in the background until ctx is done or Stop is
+// called. Either way the worker finishes the job in hand, returns the rest of
+// its batch to the queue unstarted and takes no more jobs.
+func (w *Worker) Start(ctx context.Context) {
+	leaseTimeout := w.LeaseTimeout
+	if leaseTimeout == 0 {
+		leaseTimeout = queue.DefaultLeaseTimeout
+	}
+	batchSize := w.BatchSize
+	if batchSize < 1 {
+		batchSize = 1
+	}
+
+	ctx, w.cancel = context.WithCancel(ctx)
+	w.done = make(chan struct{})
 	go func() {
+		defer close(w.done)
 		for {
-			j, err := w.Queue.GetJob()
+			leases, err := w.Queue.DequeueLeases(ctx, batchSize, leaseTimeout, w.Queues...)
+			if ctx.Err() != nil {
+				w.release(leases)
+				return
+			}
 			if err != nil {
-				fmt.Println("No job found, sleeping for 1 second")
-				time.Sleep(1 * time.Second)
+				log.Printf("Worker %d failed to fetch a job : %v\n", w.ID, err)
+				select {
+				case <-ctx.Done():
+				case <-time.After(time.Second):
+				}
 				continue
 			}
-			if j.ID != "" {
-				fmt.Printf("Worker %d processing job ID : %s \n", w.ID, j.ID)
-
-				err := handleJob(j)
-				if err != nil {
-					log.Printf("Job %s failed : %v\n", j.ID, err)
-
-					j.RetryCount++
-					if j.RetryCount <= j.MaxRetries {
-						delay := time.Duration(math.Pow(2, float64(j.RetryCount))) * time.Second
-						log.Printf("Retrying job %s in %v \n", j.ID, delay)
-
-						go func(jobCopy utils.Job) {
-							time.Sleep(delay)
-							w.Queue.AddJob(jobCopy)
-						}(j)
-					} else {
-						log.Printf("Job %s moved dto dead-letter queue \n", j.ID)
-						w.Queue.MoveJobToDeadLetterQueue(j)
+			for i, l := range leases {
+				if ctx.Err() != nil {
+					w.release(leases[i:])
+					return
+				}
+				// The rest of the batch has been waiting while earlier jobs
+				// ran; a lease that ran out meanwhile belongs to another
+				// worker now.
+				if i > 0 {
+					if err := w.Queue.Extend(l, leaseTimeout); err != nil {
+						log.Printf("Worker %d lost job %s : %v\n", w.ID, l.Job.ID, err)
+						continue
 					}
 				}
-			} else {
-				time.Sleep(1 * time.Second)
+				w.process(l, leaseTimeout)
 			}
 		}
 	}()
 }
 
-func handleJob(j utils.Job) error {
-	time.Sleep(500 * time.Millisecond)
+// Stop stops a started worker and waits up to DrainTimeout for the job in
+// hand to finish, returning ErrDrainTimeout if it does not.
+func (w *Worker) Stop() error {
+	if w.cancel == nil {
+		return nil
+	}
+	w.cancel()
+
+	timeout := w.DrainTimeout
+	if timeout == 0 {
+		timeout = DefaultDrainTimeout
+	}
+	select {
+	case <-w.done:
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("worker %d: %w", w.ID, ErrDrainTimeout)
+	}
+}
+
+// release hands leased jobs that were never started back to the queue.
+func (w *Worker) release(leases []*queue.Lease) {
+	for _, l := range leases {
+		if err := w.Queue.Nack(l, 0); err != nil {
+			log.Printf("Worker %d failed to return job %s : %v\n", w.ID, l.Job.ID, err)
+		}
+	}
+}
+
+func (w *Worker) process(l *queue.Lease, leaseTimeout time.Duration) {
+	j := l.Job
+	fmt.Printf("Worker %d processing job ID : %s \n", w.ID, j.ID)
+	if w.pool != nil {
+		w.pool.busy.Add(1)
+		defer w.pool.busy.Add(-1)
+	}
 
-	if j.Payload["to"] == "error@error.com" {
-		return fmt.Errorf("simulated error")
+	handlers := w.Handlers
+	if handlers == nil {
+		handlers = DefaultRegistry
+	}
+	// A lease shorter than the job's timeout could run out mid-attempt if
+	// the worker stalls, handing the job to a second worker, so it is made
+	// to cover the whole timeout first.
+	if timeout := handlers.timeout(j); timeout > 0 && timeout+timeoutMargin > leaseTimeout {
+		leaseTimeout = timeout + timeoutMargin
+		if err := w.Queue.Extend(l, leaseTimeout); err != nil {
+			log.Printf("Worker %d lost job %s : %v\n", w.ID, j.ID, err)
+			return
+		}
+	}
+	// The job in hand runs to completion or its timeout even while the
+	// worker stops, so its context does not derive from the worker's.
+	stop := w.keepLeased(l, leaseTimeout)
+	result, err := handlers.handle(context.Background(), j)
+	stop()
+	w.pool.count(err)
+	if errors.Is(err, ErrNoHandler) {
+		// Retrying cannot help until a handler is registered.
+		log.Printf("Job %s moved to dead-letter queue : %v\n", j.ID, err)
+		j.DeadReason = err.Error()
+		w.Queue.MoveJobToDeadLetterQueue(j)
+	} else if err != nil {
+		log.Printf("Job %s failed : %v\n", j.ID, err)
+
+		j.RetryCount++
+		if j.RetryCount <= j.MaxRetries {
+			delay := time.Duration(math.Pow(2, float64(j.RetryCount))) * time.Second
+			log.Printf("Retrying job %s in %v \n", j.ID, delay)
+
+			l.Job = j
+			if err := w.Queue.Nack(l, delay); err != nil {
+				log.Printf("Failed to release job %s : %v\n", j.ID, err)
+			}
+		} else {
+			log.Printf("Job %s moved to dead-letter queue \n", j.ID)
+			j.DeadReason = err.Error()
+			w.Queue.MoveJobToDeadLetterQueue(j)
+		}
+	} else if err := w.Queue.AckResult(l, result); err != nil {
+		log.Printf("Failed to ack job %s : %v\n", j.ID, err)
 	}
+}
 
-	fmt.Printf("Handled job : %s for %s\n", j.ID, j.Payload["to"])
-	return nil
+// keepLeased extends l by timeout every half timeout until the returned
+// function is called, so that a job running longer than its lease is not
+// handed to another worker meanwhile.
+func (w *Worker) keepLeased(l *queue.Lease, timeout time.Duration) (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(timeout / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := w.Queue.Extend(l, timeout); err != nil {
+					log.Printf("Worker %d lost job %s : %v\n", w.ID, l.Job.ID, err)
+					return
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
 }
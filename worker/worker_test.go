@@ -1,8 +1,13 @@
 package worker
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -10,6 +15,21 @@ import (
 	"github.com/Avik-creator/utils"
 )
 
+// sendEmail simulates an email handler: it takes 500ms and fails for
+// error@error.com.
+func sendEmail(ctx context.Context, j utils.Job) ([]byte, error) {
+	time.Sleep(500 * time.Millisecond)
+
+	if j.Payload["to"] == "error@error.com" {
+		return nil, fmt.Errorf("simulated error")
+	}
+	return json.Marshal(map[string]string{"sent_to": j.Payload["to"]})
+}
+
+func init() {
+	Register("email", HandlerFunc(sendEmail))
+}
+
 func TestWorker_SuccessfulJobProcessing(t *testing.T) {
 	q := queue.NewQueue()
 	w := &Worker{
@@ -30,7 +50,7 @@ func TestWorker_SuccessfulJobProcessing(t *testing.T) {
 
 	// Add job and start worker
 	q.AddJob(job)
-	w.Start()
+	w.Start(context.Background())
 
 	// Wait for processing (handleJob takes 500ms)
 	time.Sleep(600 * time.Millisecond)
@@ -40,6 +60,15 @@ func TestWorker_SuccessfulJobProcessing(t *testing.T) {
 	if len(highJobs) != 0 {
 		t.Errorf("Expected job to be processed and removed from queue, but found %d jobs", len(highJobs))
 	}
+
+	// Check that the job's result was stored
+	result, err := q.GetResult(job.ID)
+	if err != nil {
+		t.Fatalf("Expected the job's result to be stored, got %v", err)
+	}
+	if string(result.Data) != `{"sent_to":"user@example.com"}` {
+		t.Errorf("Unexpected result %s", result.Data)
+	}
 }
 
 // TestWorker_JobFailureAndRetry removed due to complexity of testing asynchronous retry with exponential backoff
@@ -65,7 +94,7 @@ func TestWorker_JobFailureMaxRetries(t *testing.T) {
 
 	// Add job and start worker
 	q.AddJob(job)
-	w.Start()
+	w.Start(context.Background())
 
 	// Wait for first attempt (500ms) + retry delay (2^1 = 2 seconds) + second attempt (500ms) + buffer
 	time.Sleep(4 * time.Second)
@@ -94,7 +123,10 @@ func TestWorker_JobFailureMaxRetries(t *testing.T) {
 		}
 	} else {
 		// Verify the job is in dead letter queue
-		_, _, _, _ = q.GetAllDeadLetterJobs()
+		highDeadJobs, _, _, _ := q.GetAllDeadLetterJobs()
+		if highDeadJobs[0].DeadReason != "simulated error" {
+			t.Errorf("Expected the dead letter job to carry its last error, got %q", highDeadJobs[0].DeadReason)
+		}
 	}
 }
 
@@ -140,7 +172,7 @@ func TestWorker_MultipleJobs(t *testing.T) {
 	for _, job := range jobs {
 		q.AddJob(job)
 	}
-	w.Start()
+	w.Start(context.Background())
 
 	// Wait for all jobs to be processed (3 jobs * 500ms each)
 	time.Sleep(2 * time.Second)
@@ -181,7 +213,7 @@ func TestWorker_MultipleWorkers(t *testing.T) {
 
 	// Start all workers
 	for _, w := range workers {
-		w.Start()
+		w.Start(context.Background())
 	}
 
 	// Wait for all jobs to be processed
@@ -202,7 +234,7 @@ func TestWorker_NoJobAvailable(t *testing.T) {
 	}
 
 	// Start worker without adding jobs
-	w.Start()
+	w.Start(context.Background())
 
 	// Wait a bit
 	time.Sleep(100 * time.Millisecond)
@@ -225,10 +257,13 @@ func TestHandleJob_Success(t *testing.T) {
 		CreatedAt:  time.Now(),
 	}
 
-	err := handleJob(job)
+	result, err := DefaultRegistry.handle(context.Background(), job)
 	if err != nil {
 		t.Errorf("Expected successful job handling, but got error: %v", err)
 	}
+	if string(result) != `{"sent_to":"user@example.com"}` {
+		t.Errorf("Expected the job's result, got %s", result)
+	}
 }
 
 func TestHandleJob_Error(t *testing.T) {
@@ -242,7 +277,7 @@ func TestHandleJob_Error(t *testing.T) {
 		CreatedAt:  time.Now(),
 	}
 
-	err := handleJob(job)
+	_, err := DefaultRegistry.handle(context.Background(), job)
 	if err == nil {
 		t.Error("Expected error for job with error@error.com, but got no error")
 	}
@@ -260,7 +295,7 @@ func TestWorker_ConcurrentJobAddition(t *testing.T) {
 		Queue: q,
 	}
 
-	w.Start()
+	w.Start(context.Background())
 
 	var wg sync.WaitGroup
 
@@ -337,7 +372,7 @@ func TestWorker_PriorityProcessing(t *testing.T) {
 		q.AddJob(job)
 	}
 
-	w.Start()
+	w.Start(context.Background())
 
 	// Wait for all jobs to be processed
 	time.Sleep(2 * time.Second)
@@ -350,3 +385,243 @@ func TestWorker_PriorityProcessing(t *testing.T) {
 		t.Errorf("Expected all jobs to be processed regardless of priority, but found %d jobs remaining", totalJobs)
 	}
 }
+
+func TestWorker_LongJobKeepsItsLease(t *testing.T) {
+	q := queue.NewQueue()
+	var runs atomic.Int32
+	r := NewRegistry()
+	r.Register("slow", HandlerFunc(func(ctx context.Context, j utils.Job) ([]byte, error) {
+		runs.Add(1)
+		time.Sleep(700 * time.Millisecond)
+		return []byte("done"), nil
+	}))
+	q.AddJob(utils.Job{ID: "slow-job", Type: "slow", Priority: utils.High, CreatedAt: time.Now()})
+
+	for i := 1; i <= 3; i++ {
+		w := &Worker{ID: i, Queue: q, Handlers: r, LeaseTimeout: 200 * time.Millisecond}
+		w.Start(context.Background())
+		defer w.Stop()
+	}
+
+	time.Sleep(1500 * time.Millisecond)
+	if n := runs.Load(); n != 1 {
+		t.Errorf("Expected the job to run once, ran %d times", n)
+	}
+	if r, err := q.GetResult("slow-job"); err != nil || string(r.Data) != "done" {
+		t.Errorf("Expected the job to be acknowledged with its result, got %q (%v)", r.Data, err)
+	}
+}
+
+func TestWorker_NamedQueues(t *testing.T) {
+	q := queue.NewQueue()
+	w := &Worker{
+		ID:     1,
+		Queue:  q,
+		Queues: []string{"emails"},
+	}
+
+	q.AddJob(utils.Job{ID: "email", Type: "email", Queue: "emails", Payload: map[string]string{"to": "user@example.com"}, Priority: utils.Low, CreatedAt: time.Now()})
+	q.AddJob(utils.Job{ID: "report", Type: "email", Queue: "reports", Payload: map[string]string{"to": "user@example.com"}, Priority: utils.High, CreatedAt: time.Now()})
+	w.Start(context.Background())
+
+	time.Sleep(600 * time.Millisecond)
+
+	if _, _, lowJobs, _ := q.GetAllJobs("emails"); len(lowJobs) != 0 {
+		t.Errorf("Expected the emails job to be processed, got %v", lowJobs)
+	}
+	if highJobs, _, _, _ := q.GetAllJobs("reports"); len(highJobs) != 1 {
+		t.Errorf("Expected the reports job to be left alone, got %v", highJobs)
+	}
+}
+
+func TestWorker_BatchSize(t *testing.T) {
+	q := queue.NewQueue()
+	w := &Worker{
+		ID:        1,
+		Queue:     q,
+		BatchSize: 3,
+	}
+
+	var jobs []utils.Job
+	for i := 0; i < 3; i++ {
+		jobs = append(jobs, utils.Job{ID: fmt.Sprintf("batch-job-%d", i), Type: "email", Payload: map[string]string{"to": "user@example.com"}, Priority: utils.Medium, CreatedAt: time.Now()})
+	}
+	q.AddJobs(jobs)
+	w.Start(context.Background())
+
+	time.Sleep(100 * time.Millisecond)
+	if _, mediumJobs, _, _ := q.GetAllJobs(); len(mediumJobs) != 0 {
+		t.Errorf("Expected the worker to fetch the whole batch at once, %d jobs left", len(mediumJobs))
+	}
+
+	// The jobs are processed one after another, 500ms each.
+	time.Sleep(1700 * time.Millisecond)
+	if _, err := q.Lease(time.Second); !errors.Is(err, queue.ErrEmpty) {
+		t.Errorf("Expected every job of the batch to be acked, got %v", err)
+	}
+}
+
+func TestWorker_UnknownTypeGoesToDeadLetterQueue(t *testing.T) {
+	q := queue.NewQueue()
+	w := &Worker{ID: 1, Queue: q, Handlers: NewRegistry()}
+
+	q.AddJob(utils.Job{ID: "fax-job", Type: "fax", Priority: utils.High, MaxRetries: 3, CreatedAt: time.Now()})
+	w.Start(context.Background())
+
+	time.Sleep(100 * time.Millisecond)
+	highDeadJobs, _, _, _ := q.GetAllDeadLetterJobs()
+	if len(highDeadJobs) != 1 {
+		t.Fatalf("Expected the job to go straight to the dead letter queue, got %v", highDeadJobs)
+	}
+	if dead := highDeadJobs[0]; dead.RetryCount != 0 || !strings.Contains(dead.DeadReason, `"fax"`) {
+		t.Errorf("Expected no retries and a reason naming the type, got %d retries and %q", dead.RetryCount, dead.DeadReason)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	r.Register("echo", HandlerFunc(func(ctx context.Context, j utils.Job) ([]byte, error) {
+		return []byte(j.Payload["msg"]), nil
+	}))
+
+	result, err := r.handle(context.Background(), utils.Job{Type: "echo", Payload: map[string]string{"msg": "hi"}})
+	if err != nil || string(result) != "hi" {
+		t.Errorf("Expected the echo handler to run, got %q (%v)", result, err)
+	}
+	if _, err := r.handle(context.Background(), utils.Job{Type: "email"}); !errors.Is(err, ErrNoHandler) {
+		t.Errorf("Expected ErrNoHandler for a type registered only in DefaultRegistry, got %v", err)
+	}
+}
+
+func TestWorker_LeaseCoversJobTimeout(t *testing.T) {
+	m := queue.NewMemoryStorage()
+	q := queue.NewQueue(queue.WithStorage(m))
+	started, finish := make(chan struct{}), make(chan struct{})
+	r := NewRegistry()
+	r.Register("slow", HandlerFunc(func(ctx context.Context, j utils.Job) ([]byte, error) {
+		close(started)
+		<-finish
+		return nil, nil
+	}))
+	q.AddJob(utils.Job{ID: "slow-job", Type: "slow", Priority: utils.High, CreatedAt: time.Now(), Timeout: time.Second})
+
+	w := &Worker{ID: 1, Queue: q, Handlers: r, LeaseTimeout: 100 * time.Millisecond}
+	w.Start(context.Background())
+	defer w.Stop()
+
+	<-started
+	held, err := m.ListInFlight()
+	close(finish)
+	if err != nil || len(held) != 1 {
+		t.Fatalf("Expected the job to be held, got %v (%v)", held, err)
+	}
+	if left := time.Until(held[0].Until); left < time.Second {
+		t.Errorf("Expected the lease to outlast the job's timeout, it runs out in %v", left)
+	}
+}
+
+func TestRegistry_Timeout(t *testing.T) {
+	r := NewRegistry()
+	r.Register("hang", HandlerFunc(func(ctx context.Context, j utils.Job) ([]byte, error) {
+		time.Sleep(time.Second)
+		return nil, nil
+	}))
+	r.Register("wait", HandlerFunc(func(ctx context.Context, j utils.Job) ([]byte, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}))
+
+	start := time.Now()
+	if _, err := r.handle(context.Background(), utils.Job{Type: "hang", Timeout: 50 * time.Millisecond}); !errors.Is(err, ErrJobTimeout) {
+		t.Errorf("Expected ErrJobTimeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected a hung handler to be abandoned at the timeout, waited %v", elapsed)
+	}
+
+	r.SetTimeout("wait", 50*time.Millisecond)
+	if _, err := r.handle(context.Background(), utils.Job{Type: "wait"}); !errors.Is(err, ErrJobTimeout) {
+		t.Errorf("Expected the type's timeout to apply, got %v", err)
+	}
+	if _, err := r.handle(context.Background(), utils.Job{Type: "hang", Timeout: 2 * time.Second}); err != nil {
+		t.Errorf("Expected the job to finish within its own timeout, got %v", err)
+	}
+}
+
+func TestWorker_TimedOutJobGoesToDeadLetterQueue(t *testing.T) {
+	q := queue.NewQueue()
+	r := NewRegistry()
+	r.Register("hang", HandlerFunc(func(ctx context.Context, j utils.Job) ([]byte, error) {
+		select {}
+	}))
+	w := &Worker{ID: 1, Queue: q, Handlers: r}
+
+	q.AddJob(utils.Job{ID: "hung-job", Type: "hang", Priority: utils.High, CreatedAt: time.Now(), Timeout: 100 * time.Millisecond})
+	w.Start(context.Background())
+	defer w.Stop()
+
+	time.Sleep(300 * time.Millisecond)
+	highDeadJobs, _, _, _ := q.GetAllDeadLetterJobs()
+	if len(highDeadJobs) != 1 || !strings.Contains(highDeadJobs[0].DeadReason, ErrJobTimeout.Error()) {
+		t.Fatalf("Expected the hung job to be dead-lettered for timing out, got %v", highDeadJobs)
+	}
+}
+
+func TestWorker_StopDrainsRunningJob(t *testing.T) {
+	q := queue.NewQueue()
+	w := &Worker{ID: 1, Queue: q, BatchSize: 3}
+
+	for i := 0; i < 3; i++ {
+		q.AddJob(utils.Job{ID: fmt.Sprintf("drain-job-%d", i), Type: "email", Payload: map[string]string{"to": "user@example.com"}, Priority: utils.High, CreatedAt: time.Now()})
+	}
+	w.Start(context.Background())
+
+	// Stop while the first job of the batch is running.
+	time.Sleep(100 * time.Millisecond)
+	if err := w.Stop(); err != nil {
+		t.Fatalf("Expected the running job to be drained, got %v", err)
+	}
+	if _, err := q.GetResult("drain-job-0"); err != nil {
+		t.Errorf("Expected the running job to finish, got %v", err)
+	}
+	if highJobs, _, _, _ := q.GetAllJobs(); len(highJobs) != 2 {
+		t.Errorf("Expected the unstarted jobs back in the queue, got %v", highJobs)
+	}
+
+	// A stopped worker takes no more jobs.
+	time.Sleep(600 * time.Millisecond)
+	if highJobs, _, _, _ := q.GetAllJobs(); len(highJobs) != 2 {
+		t.Errorf("Expected the stopped worker to leave the queue alone, got %v", highJobs)
+	}
+}
+
+func TestWorker_StopDrainTimeout(t *testing.T) {
+	q := queue.NewQueue()
+	w := &Worker{ID: 1, Queue: q, DrainTimeout: 100 * time.Millisecond}
+
+	q.AddJob(utils.Job{ID: "slow-job", Type: "email", Payload: map[string]string{"to": "user@example.com"}, Priority: utils.High, CreatedAt: time.Now()})
+	w.Start(context.Background())
+
+	time.Sleep(50 * time.Millisecond)
+	if err := w.Stop(); !errors.Is(err, ErrDrainTimeout) {
+		t.Errorf("Expected ErrDrainTimeout, got %v", err)
+	}
+}
+
+func TestWorker_ContextCancellation(t *testing.T) {
+	q := queue.NewQueue()
+	w := &Worker{ID: 1, Queue: q}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w.Start(ctx)
+	cancel()
+	if err := w.Stop(); err != nil {
+		t.Fatalf("Expected an idle worker to stop at once, got %v", err)
+	}
+
+	q.AddJob(utils.Job{ID: "late-job", Type: "email", Payload: map[string]string{"to": "user@example.com"}, Priority: utils.High, CreatedAt: time.Now()})
+	time.Sleep(50 * time.Millisecond)
+	if highJobs, _, _, _ := q.GetAllJobs(); len(highJobs) != 1 {
+		t.Errorf("Expected the cancelled worker to take no jobs, got %v", highJobs)
+	}
+}